@@ -0,0 +1,53 @@
+package i2c
+
+// Bank accesses a device whose registers are banked: a bank select
+// register chooses which set of registers the other addresses refer
+// to. This is common on audio codecs.
+type Bank struct {
+	c   *Conn
+	sel byte
+}
+
+// NewBank returns a Bank for the device connected to c, using
+// register sel to select the bank.
+func NewBank(c *Conn, sel byte) *Bank {
+	return &Bank{c: c, sel: sel}
+}
+
+// selectBank writes bank to the bank select register. The caller
+// must hold b.c.mu.
+func (b *Bank) selectBank(bank byte) error {
+	return b.c.writeRegs(b.sel, []byte{bank})
+}
+
+// ReadReg reads register reg of the indicated bank. The bank select
+// and the register read are performed without releasing the
+// connection.
+func (b *Bank) ReadReg(bank, reg byte) (byte, error) {
+	if err := b.c.lock(); err != nil {
+		return 0, err
+	}
+	defer b.c.mu.Unlock()
+	if err := b.selectBank(bank); err != nil {
+		return 0, err
+	}
+	var d [1]byte
+	if _, err := b.c.readReg(reg, d[:]); err != nil {
+		return 0, err
+	}
+	return d[0], nil
+}
+
+// WriteReg writes val to register reg of the indicated bank. The bank
+// select and the register write are performed without releasing the
+// connection.
+func (b *Bank) WriteReg(bank, reg, val byte) error {
+	if err := b.c.lock(); err != nil {
+		return err
+	}
+	defer b.c.mu.Unlock()
+	if err := b.selectBank(bank); err != nil {
+		return err
+	}
+	return b.c.writeRegs(reg, []byte{val})
+}
@@ -0,0 +1,41 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestBank(t *testing.T) {
+	a := newFakeAdapter(0x1a)
+	d := a.devs[0x1a]
+	c := newFakeConn(t, a, 0x1a, binary.BigEndian)
+	b := NewBank(c, 0xff)
+
+	if err := b.WriteReg(1, 0x10, 0x55); err != nil {
+		t.Fatalf("WriteReg failed: %v", err)
+	}
+	if got, want := a.ops(), []string{"w 1a: ff 01", "w 1a: 10 55"}; !equalStrings(got, want) {
+		t.Errorf("WriteReg: got %q, want %q", got, want)
+	}
+	d.regs[0x11] = 0x66
+	v, err := b.ReadReg(2, 0x11)
+	if err != nil {
+		t.Fatalf("ReadReg failed: %v", err)
+	}
+	if v != 0x66 {
+		t.Errorf("ReadReg got %#02x, want 0x66", v)
+	}
+	if got, want := a.ops(), []string{"w 1a: ff 02", "w 1a: 11", "r 1a: 1"}; !equalStrings(got, want) {
+		t.Errorf("ReadReg: got %q, want %q", got, want)
+	}
+
+	d.nak = true
+	if err := b.WriteReg(3, 0x10, 0x77); !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("failed select: got %v, want %v", err, syscall.ENXIO)
+	}
+	if got, want := a.ops(), []string{"w 1a: ff 03"}; !equalStrings(got, want) {
+		t.Errorf("failed select: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package i2c
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCRC indicates data read from a device failed its CRC check.
+var ErrCRC = errors.New("CRC check failed")
+
+// crc8 computes the CRC-8 used by Sensirion and similar devices:
+// polynomial 0x31, initial value 0xff, no reflection and no final
+// XOR.
+func crc8(data []byte) byte {
+	crc := byte(0xff)
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x31
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// ReadCheckedRetry reads n bytes of data, starting at register reg,
+// from a device that follows each 2 byte word with a CRC-8 byte, as
+// Sensirion sensors do. The data is returned without the CRC bytes,
+// so n must be a positive multiple of 2. A read that fails its CRC
+// check, typically due to bus noise, is repeated, up to attempts
+// reads in all, after which an error wrapping ErrCRC is returned.
+// Other errors are returned immediately.
+func (c *Conn) ReadCheckedRetry(reg byte, n, attempts int) ([]byte, error) {
+	if n < 2 || n%2 != 0 || n/2*3 > maxMsg || attempts < 1 {
+		return nil, ErrInvalid
+	}
+	buf := make([]byte, n/2*3)
+	var err error
+	for try := 1; try <= attempts; try++ {
+		if _, err := c.Regs(reg, buf); err != nil {
+			return nil, err
+		}
+		err = nil
+		data := make([]byte, 0, n)
+		for i := 0; i < len(buf); i += 3 {
+			if crc8(buf[i:i+2]) != buf[i+2] {
+				err = fmt.Errorf("word %d of read %d of %d: %w", i/3, try, attempts, ErrCRC)
+				break
+			}
+			data = append(data, buf[i:i+2]...)
+		}
+		if err == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
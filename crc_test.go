@@ -0,0 +1,75 @@
+package i2c
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestCRC8(t *testing.T) {
+	// The example of the Sensirion SHT3x datasheet.
+	if got := crc8([]byte{0xbe, 0xef}); got != 0x92 {
+		t.Errorf("got %#02x, want 0x92", got)
+	}
+	if got := crc8(nil); got != 0xff {
+		t.Errorf("empty data got %#02x, want 0xff", got)
+	}
+}
+
+func TestReadCheckedRetry(t *testing.T) {
+	a := newFakeAdapter(0x44)
+	d := a.devs[0x44]
+	c := newFakeConn(t, a, 0x44, binary.BigEndian)
+	good := []byte{0xbe, 0xef, 0x92, 0x12, 0x34, crc8([]byte{0x12, 0x34})}
+	bad := append([]byte(nil), good...)
+	bad[4] ^= 0x01
+	var reads, bads [][]byte
+	d.onRead = func(buf []byte) {
+		r := good
+		if len(reads) < len(bads) {
+			r = bads[len(reads)]
+		}
+		reads = append(reads, r)
+		copy(buf, r)
+	}
+
+	bads = [][]byte{bad}
+	got, err := c.ReadCheckedRetry(0xe0, 4, 3)
+	if err != nil {
+		t.Fatalf("ReadCheckedRetry failed: %v", err)
+	}
+	if want := []byte{0xbe, 0xef, 0x12, 0x34}; !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+	if len(reads) != 2 {
+		t.Errorf("got %d reads, want 2", len(reads))
+	}
+
+	reads, bads = nil, [][]byte{bad, bad, bad}
+	if _, err := c.ReadCheckedRetry(0xe0, 4, 3); !errors.Is(err, ErrCRC) {
+		t.Errorf("persistent corruption got %v, want ErrCRC", err)
+	}
+	if len(reads) != 3 {
+		t.Errorf("got %d reads, want 3", len(reads))
+	}
+
+	reads, bads = nil, nil
+	d.nak = true
+	if _, err := c.ReadCheckedRetry(0xe0, 4, 3); !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("NACK got %v, want the bus error", err)
+	}
+	if len(reads) != 0 {
+		t.Errorf("NACK'd read was retried")
+	}
+
+	for _, n := range []int{0, 3} {
+		if _, err := c.ReadCheckedRetry(0xe0, n, 1); err != ErrInvalid {
+			t.Errorf("n=%d got %v, want ErrInvalid", n, err)
+		}
+	}
+	if _, err := c.ReadCheckedRetry(0xe0, 2, 0); err != ErrInvalid {
+		t.Errorf("no attempts got %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,380 @@
+// Package ddc reads the identity of, and controls, a display monitor
+// over the i2c bus of a video connector.
+//
+// Graphics drivers typically create an i2c-dev bus node for each
+// connector. On it, the monitor's EDID is found at address 0x50 and
+// its DDC/CI command interface at 0x37. The DDC/CI framing and
+// command set are defined by the VESA DDC/CI and MCCS standards.
+package ddc // zappem.net/pub/io/i2c/ddc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zappem.net/pub/io/i2c"
+)
+
+// EDIDAddr and CIAddr are the i2c addresses of the EDID EEPROM and the
+// DDC/CI command interface.
+const (
+	EDIDAddr = 0x50
+	CIAddr   = 0x37
+)
+
+// Brightness and Contrast are the MCCS VCP feature codes for the
+// display luminance and contrast.
+const (
+	Brightness = 0x10
+	Contrast   = 0x12
+)
+
+// hostAddr etc are the DDC/CI protocol addresses, in their 8-bit
+// wire form, and the opcodes of the supported commands.
+const (
+	hostAddr    = 0x51
+	replyAddr   = 0x50
+	displayAddr = 0x6e
+
+	getVCP      = 0x01
+	getVCPReply = 0x02
+	setVCP      = 0x03
+	capsReq     = 0xf3
+	capsReply   = 0xe3
+)
+
+// getDelay and setDelay are the minimum times the host must wait
+// after a command before issuing another, or reading its reply.
+const (
+	getDelay = 40 * time.Millisecond
+	setDelay = 50 * time.Millisecond
+)
+
+// ErrChecksum etc are errors reported by the package.
+var (
+	ErrChecksum    = errors.New("checksum mismatch")
+	ErrFrame       = errors.New("malformed frame")
+	ErrUnsupported = errors.New("feature not supported by monitor")
+	ErrEDIDHeader  = errors.New("invalid EDID header")
+)
+
+// xorSum returns the exclusive-or of seed and all of the bytes of d.
+func xorSum(seed byte, d []byte) byte {
+	for _, b := range d {
+		seed ^= b
+	}
+	return seed
+}
+
+// EncodeFrame returns the bytes a host writes to the DDC/CI address
+// to send payload to the monitor: the source address, the length,
+// the payload and a checksum. The checksum covers the destination
+// address, which the i2c layer transmits as the first byte.
+func EncodeFrame(payload []byte) ([]byte, error) {
+	if len(payload) > 0x7f {
+		return nil, fmt.Errorf("%d byte payload too long: %w", len(payload), ErrFrame)
+	}
+	d := make([]byte, 0, len(payload)+3)
+	d = append(d, hostAddr, 0x80|byte(len(payload)))
+	d = append(d, payload...)
+	return append(d, xorSum(displayAddr, d)), nil
+}
+
+// DecodeFrame validates a reply frame read from the monitor and
+// returns its payload. The frame starts with the monitor's source
+// address and length, and ends in a checksum that covers the host's
+// virtual read address.
+func DecodeFrame(d []byte) ([]byte, error) {
+	if len(d) < 3 || d[0] != displayAddr || d[1]&0x80 == 0 {
+		return nil, ErrFrame
+	}
+	n := int(d[1] & 0x7f)
+	if len(d) < n+3 {
+		return nil, fmt.Errorf("%w: %d byte payload in %d bytes", ErrFrame, n, len(d))
+	}
+	if xorSum(replyAddr, d[:n+3]) != 0 {
+		return nil, ErrChecksum
+	}
+	return d[2 : 2+n], nil
+}
+
+// Monitor holds connections to the EDID and DDC/CI interfaces of a
+// monitor.
+type Monitor struct {
+	mu      sync.Mutex
+	edid    io.ReadWriter
+	ci      io.ReadWriter
+	closers []io.Closer
+	// ready is when the monitor may next be sent a command.
+	ready time.Time
+}
+
+// New returns a Monitor using the provided EDID and DDC/CI
+// interfaces.
+func New(edid, ci io.ReadWriter) *Monitor {
+	return &Monitor{edid: edid, ci: ci}
+}
+
+// Open connects to the monitor attached to the named bus device file.
+func Open(bus string) (*Monitor, error) {
+	e, err := i2c.NewConn(bus, EDIDAddr, false, binary.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	c, err := i2c.NewConn(bus, CIAddr, false, binary.BigEndian)
+	if err != nil {
+		e.Close()
+		return nil, err
+	}
+	m := New(e, c)
+	m.closers = []io.Closer{e, c}
+	return m, nil
+}
+
+// Close closes the connections opened by Open.
+func (m *Monitor) Close() error {
+	var errs []error
+	for _, c := range m.closers {
+		errs = append(errs, c.Close())
+	}
+	m.closers = nil
+	return errors.Join(errs...)
+}
+
+// readFull reads len(d) bytes from r as a single read.
+func readFull(r io.Reader, d []byte) error {
+	n, err := r.Read(d)
+	if err != nil {
+		return err
+	}
+	if n != len(d) {
+		return i2c.ErrTruncated
+	}
+	return nil
+}
+
+// RawEDID reads the base EDID block and, if the monitor indicates it
+// has one, the first extension block. Each block's checksum is
+// validated.
+func (m *Monitor) RawEDID() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := make([]byte, 128)
+	if _, err := m.edid.Write([]byte{0}); err != nil {
+		return nil, err
+	}
+	if err := readFull(m.edid, d); err != nil {
+		return nil, err
+	}
+	if err := blockSum(d); err != nil {
+		return nil, err
+	}
+	if d[126] == 0 {
+		return d, nil
+	}
+	ext := make([]byte, 128)
+	if _, err := m.edid.Write([]byte{128}); err != nil {
+		return nil, err
+	}
+	if err := readFull(m.edid, ext); err != nil {
+		return nil, err
+	}
+	if err := blockSum(ext); err != nil {
+		return nil, err
+	}
+	return append(d, ext...), nil
+}
+
+// blockSum validates the checksum of a 128 byte EDID block.
+func blockSum(d []byte) error {
+	var sum byte
+	for _, b := range d {
+		sum += b
+	}
+	if sum != 0 {
+		return fmt.Errorf("EDID block %w", ErrChecksum)
+	}
+	return nil
+}
+
+// EDID holds the identity of a monitor decoded from its EDID.
+type EDID struct {
+	Manufacturer string
+	Product      uint16
+	Serial       uint32
+	Week, Year   int
+	Version      string
+	// Name and SerialText are from the display descriptors, if
+	// present.
+	Name, SerialText string
+	// Extensions is the number of extension blocks.
+	Extensions int
+}
+
+// edidHeader starts every base EDID block.
+var edidHeader = []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}
+
+// ParseEDID decodes the base block of raw EDID data, as returned by
+// RawEDID.
+func ParseEDID(d []byte) (*EDID, error) {
+	if len(d) < 128 {
+		return nil, i2c.ErrTruncated
+	}
+	if !bytes.Equal(d[:8], edidHeader) {
+		return nil, ErrEDIDHeader
+	}
+	if err := blockSum(d[:128]); err != nil {
+		return nil, err
+	}
+	id := binary.BigEndian.Uint16(d[8:10])
+	e := &EDID{
+		Manufacturer: string([]byte{
+			'@' + byte(id>>10&0x1f),
+			'@' + byte(id>>5&0x1f),
+			'@' + byte(id&0x1f),
+		}),
+		Product:    binary.LittleEndian.Uint16(d[10:12]),
+		Serial:     binary.LittleEndian.Uint32(d[12:16]),
+		Week:       int(d[16]),
+		Year:       1990 + int(d[17]),
+		Version:    fmt.Sprintf("%d.%d", d[18], d[19]),
+		Extensions: int(d[126]),
+	}
+	for off := 54; off < 126; off += 18 {
+		desc := d[off : off+18]
+		if desc[0] != 0 || desc[1] != 0 {
+			continue // detailed timing descriptor
+		}
+		text := strings.TrimRight(string(bytes.SplitN(desc[5:], []byte{'\n'}, 2)[0]), " ")
+		switch desc[3] {
+		case 0xfc:
+			e.Name = text
+		case 0xff:
+			e.SerialText = text
+		}
+	}
+	return e, nil
+}
+
+// command sends a DDC/CI command with payload and, if reply is
+// non-zero, reads a reply frame of up to reply payload bytes after
+// the mandated delay. The caller must hold m.mu.
+func (m *Monitor) command(payload []byte, delay time.Duration, reply int) ([]byte, error) {
+	frame, err := EncodeFrame(payload)
+	if err != nil {
+		return nil, err
+	}
+	if d := time.Until(m.ready); d > 0 {
+		time.Sleep(d)
+	}
+	_, err = m.ci.Write(frame)
+	m.ready = time.Now().Add(delay)
+	if err != nil || reply == 0 {
+		return nil, err
+	}
+	time.Sleep(delay)
+	d := make([]byte, reply+3)
+	n, err := m.ci.Read(d)
+	m.ready = time.Now().Add(delay)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeFrame(d[:n])
+}
+
+// GetVCP reads the current and maximum values of a VCP feature.
+func (m *Monitor) GetVCP(code byte) (cur, max uint16, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, err := m.command([]byte{getVCP, code}, getDelay, 8)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(p) != 8 || p[0] != getVCPReply || p[2] != code {
+		return 0, 0, ErrFrame
+	}
+	if p[1] != 0 {
+		return 0, 0, fmt.Errorf("VCP %02xh: %w", code, ErrUnsupported)
+	}
+	return binary.BigEndian.Uint16(p[6:8]), binary.BigEndian.Uint16(p[4:6]), nil
+}
+
+// SetVCP sets the value of a VCP feature.
+func (m *Monitor) SetVCP(code byte, val uint16) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.command([]byte{setVCP, code, byte(val >> 8), byte(val)}, setDelay, 0)
+	return err
+}
+
+// Capabilities reads the monitor's MCCS capabilities string, for
+// example "(prot(monitor)type(lcd)vcp(10 12 ...)mccs_ver(2.1))".
+func (m *Monitor) Capabilities() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var caps []byte
+	for {
+		off := len(caps)
+		p, err := m.command([]byte{capsReq, byte(off >> 8), byte(off)}, setDelay, 35)
+		if err != nil {
+			return "", err
+		}
+		if len(p) < 3 || p[0] != capsReply || int(binary.BigEndian.Uint16(p[1:3])) != off {
+			return "", ErrFrame
+		}
+		if len(p) == 3 {
+			break
+		}
+		caps = append(caps, p[3:]...)
+	}
+	return strings.TrimRight(string(caps), "\x00"), nil
+}
+
+// VCPCodes extracts the list of VCP feature codes from a capabilities
+// string, ignoring the value lists that qualify some of them.
+func VCPCodes(caps string) ([]byte, error) {
+	i := strings.Index(caps, "vcp(")
+	if i < 0 {
+		return nil, nil
+	}
+	var codes []byte
+	depth := 0
+	tok := ""
+	flush := func() error {
+		defer func() { tok = "" }()
+		if tok == "" || depth != 0 {
+			return nil
+		}
+		v, err := strconv.ParseUint(tok, 16, 8)
+		if err != nil {
+			return fmt.Errorf("bad VCP code %q: %w", tok, ErrFrame)
+		}
+		codes = append(codes, byte(v))
+		return nil
+	}
+	for _, r := range caps[i+4:] {
+		switch r {
+		case '(', ')', ' ':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			if r == '(' {
+				depth++
+			} else if r == ')' {
+				if depth == 0 {
+					return codes, nil
+				}
+				depth--
+			}
+		default:
+			tok += string(r)
+		}
+	}
+	return codes, nil
+}
@@ -0,0 +1,271 @@
+package ddc
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"zappem.net/pub/io/i2c"
+)
+
+// The frames below are from the examples of the VESA DDC/CI standard,
+// as also logged by ddcutil.
+
+func TestEncodeFrame(t *testing.T) {
+	vs := []struct {
+		name    string
+		payload []byte
+		want    []byte
+	}{
+		{"get brightness", []byte{getVCP, Brightness}, []byte{0x51, 0x82, 0x01, 0x10, 0xac}},
+		{"set brightness", []byte{setVCP, Brightness, 0x00, 0x32}, []byte{0x51, 0x84, 0x03, 0x10, 0x00, 0x32, 0x9a}},
+		{"capabilities", []byte{capsReq, 0x00, 0x00}, []byte{0x51, 0x83, 0xf3, 0x00, 0x00, 0x4f}},
+		{"null", nil, []byte{0x51, 0x80, 0xbf}},
+	}
+	for _, v := range vs {
+		got, err := EncodeFrame(v.payload)
+		if err != nil {
+			t.Errorf("%s: EncodeFrame failed: %v", v.name, err)
+		} else if !bytes.Equal(got, v.want) {
+			t.Errorf("%s: got % x, want % x", v.name, got, v.want)
+		}
+	}
+	if _, err := EncodeFrame(make([]byte, 0x80)); !errors.Is(err, ErrFrame) {
+		t.Errorf("oversized payload got %v, want ErrFrame", err)
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	vs := []struct {
+		name  string
+		frame []byte
+		want  []byte
+		err   error
+	}{
+		{"brightness reply", []byte{0x6e, 0x88, 0x02, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x32, 0xf2}, []byte{0x02, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x32}, nil},
+		{"null", []byte{0x6e, 0x80, 0xbe}, []byte{}, nil},
+		{"trailing", []byte{0x6e, 0x80, 0xbe, 0xff, 0xff}, []byte{}, nil},
+		{"checksum", []byte{0x6e, 0x88, 0x02, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x32, 0xf3}, nil, ErrChecksum},
+		{"source", []byte{0x51, 0x80, 0xbf}, nil, ErrFrame},
+		{"length flag", []byte{0x6e, 0x00, 0x3e}, nil, ErrFrame},
+		{"short", []byte{0x6e, 0x88, 0x02, 0x00}, nil, ErrFrame},
+	}
+	for _, v := range vs {
+		got, err := DecodeFrame(v.frame)
+		if v.err != nil {
+			if !errors.Is(err, v.err) {
+				t.Errorf("%s: got %v, want %v", v.name, err, v.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: DecodeFrame failed: %v", v.name, err)
+		} else if !bytes.Equal(got, v.want) {
+			t.Errorf("%s: got % x, want % x", v.name, got, v.want)
+		}
+	}
+}
+
+// edidBlock returns a base EDID block for a monitor with a detailed
+// timing descriptor followed by name and serial number descriptors.
+func edidBlock(extensions byte) []byte {
+	d := make([]byte, 128)
+	copy(d, edidHeader)
+	copy(d[8:], []byte{
+		0x10, 0xac, // "DEL"
+		0xc1, 0xa0, // product
+		0x4c, 0x33, 0x32, 0x30, // serial
+		20, 29, // week 20 of 2019
+		1, 4,
+	})
+	// 1920x1080 at 60 Hz.
+	copy(d[54:], []byte{0x02, 0x3a, 0x80, 0x18, 0x71, 0x38, 0x2d, 0x40, 0x58, 0x2c, 0x45, 0x00, 0x09, 0x25, 0x21, 0x00, 0x00, 0x1e})
+	copy(d[72:], append([]byte{0, 0, 0, 0xff, 0}, "9VG3T23\n    "...))
+	copy(d[90:], append([]byte{0, 0, 0, 0xfc, 0}, "DELL U2719D\n "...))
+	copy(d[108:], append([]byte{0, 0, 0, 0x10, 0}, make([]byte, 13)...))
+	d[126] = extensions
+	var sum byte
+	for _, b := range d[:127] {
+		sum += b
+	}
+	d[127] = -sum
+	return d
+}
+
+func TestParseEDID(t *testing.T) {
+	e, err := ParseEDID(edidBlock(1))
+	if err != nil {
+		t.Fatalf("ParseEDID failed: %v", err)
+	}
+	want := &EDID{
+		Manufacturer: "DEL",
+		Product:      0xa0c1,
+		Serial:       0x3032334c,
+		Week:         20,
+		Year:         2019,
+		Version:      "1.4",
+		Name:         "DELL U2719D",
+		SerialText:   "9VG3T23",
+		Extensions:   1,
+	}
+	if !reflect.DeepEqual(e, want) {
+		t.Errorf("got %+v, want %+v", e, want)
+	}
+
+	d := edidBlock(0)
+	d[0] = 0xff
+	if _, err := ParseEDID(d); err != ErrEDIDHeader {
+		t.Errorf("bad header got %v, want ErrEDIDHeader", err)
+	}
+	d = edidBlock(0)
+	d[100] ^= 0x20
+	if _, err := ParseEDID(d); !errors.Is(err, ErrChecksum) {
+		t.Errorf("bad checksum got %v, want ErrChecksum", err)
+	}
+	if _, err := ParseEDID(d[:127]); err != i2c.ErrTruncated {
+		t.Errorf("short block got %v, want ErrTruncated", err)
+	}
+}
+
+func TestVCPCodes(t *testing.T) {
+	caps := "(prot(monitor)type(lcd)model(U2719D)cmds(01 02 03 07 0C E3 F3)vcp(02 04 10 12 14(05 08 0B) 60(0F 11) D6(01 04))mccs_ver(2.1))"
+	codes, err := VCPCodes(caps)
+	if err != nil {
+		t.Fatalf("VCPCodes failed: %v", err)
+	}
+	if want := []byte{0x02, 0x04, 0x10, 0x12, 0x14, 0x60, 0xd6}; !bytes.Equal(codes, want) {
+		t.Errorf("got % x, want % x", codes, want)
+	}
+	if _, err := VCPCodes("vcp(10 XYZ)"); !errors.Is(err, ErrFrame) {
+		t.Errorf("bad code got %v, want ErrFrame", err)
+	}
+	if codes, err := VCPCodes("(prot(monitor))"); codes != nil || err != nil {
+		t.Errorf("no vcp list got % x, %v", codes, err)
+	}
+}
+
+// fakeEDID simulates an EDID EEPROM: a write sets the offset that
+// the next read starts from.
+type fakeEDID struct {
+	d   []byte
+	off int
+}
+
+func (e *fakeEDID) Write(d []byte) (int, error) {
+	e.off = int(d[0])
+	return len(d), nil
+}
+
+func (e *fakeEDID) Read(d []byte) (int, error) {
+	return copy(d, e.d[e.off:]), nil
+}
+
+// fakeMonitor simulates the DDC/CI interface of a monitor that
+// supports the VCP features listed in vcp, as current and maximum
+// values.
+type fakeMonitor struct {
+	t     *testing.T
+	vcp   map[byte][2]uint16
+	caps  string
+	reply []byte
+	// last is when the last command was written, and gaps the
+	// time between each command and the one before.
+	last time.Time
+	gaps []time.Duration
+}
+
+func (m *fakeMonitor) Write(d []byte) (int, error) {
+	now := time.Now()
+	if !m.last.IsZero() {
+		m.gaps = append(m.gaps, now.Sub(m.last))
+	}
+	m.last = now
+	if len(d) < 3 || d[0] != hostAddr || int(d[1]&0x7f) != len(d)-3 || xorSum(displayAddr, d) != 0 {
+		m.t.Errorf("monitor received bad frame % x", d)
+		return len(d), nil
+	}
+	p := d[2 : len(d)-1]
+	var r []byte
+	switch p[0] {
+	case getVCP:
+		v, ok := m.vcp[p[1]]
+		r = []byte{getVCPReply, 0, p[1], 0, byte(v[1] >> 8), byte(v[1]), byte(v[0] >> 8), byte(v[0])}
+		if !ok {
+			r[1] = 1
+		}
+	case setVCP:
+		v := m.vcp[p[1]]
+		v[0] = uint16(p[2])<<8 | uint16(p[3])
+		m.vcp[p[1]] = v
+	case capsReq:
+		off := int(p[1])<<8 | int(p[2])
+		chunk := m.caps[off:]
+		if len(chunk) > 32 {
+			chunk = chunk[:32]
+		}
+		r = append([]byte{capsReply, p[1], p[2]}, chunk...)
+	}
+	m.reply = nil
+	if r != nil {
+		m.reply = append([]byte{displayAddr, 0x80 | byte(len(r))}, r...)
+		m.reply = append(m.reply, xorSum(replyAddr, m.reply))
+	}
+	return len(d), nil
+}
+
+func (m *fakeMonitor) Read(d []byte) (int, error) {
+	return copy(d, m.reply), nil
+}
+
+func TestMonitor(t *testing.T) {
+	ext := make([]byte, 128)
+	ext[0], ext[127] = 0x02, 0xfe
+	edid := &fakeEDID{d: append(edidBlock(1), ext...)}
+	ci := &fakeMonitor{
+		t:    t,
+		vcp:  map[byte][2]uint16{Brightness: {50, 100}, Contrast: {75, 100}},
+		caps: "(prot(monitor)type(lcd)vcp(10 12)mccs_ver(2.1))",
+	}
+	m := New(edid, ci)
+
+	raw, err := m.RawEDID()
+	if err != nil {
+		t.Fatalf("RawEDID failed: %v", err)
+	}
+	if !bytes.Equal(raw, edid.d) {
+		t.Errorf("got % x, want % x", raw, edid.d)
+	}
+	edid.d[200]++
+	if _, err := m.RawEDID(); !errors.Is(err, ErrChecksum) {
+		t.Errorf("bad extension got %v, want ErrChecksum", err)
+	}
+
+	if cur, max, err := m.GetVCP(Brightness); err != nil || cur != 50 || max != 100 {
+		t.Errorf("GetVCP got %d, %d, %v, want 50, 100", cur, max, err)
+	}
+	if err := m.SetVCP(Brightness, 80); err != nil {
+		t.Errorf("SetVCP failed: %v", err)
+	}
+	if cur, _, err := m.GetVCP(Brightness); err != nil || cur != 80 {
+		t.Errorf("GetVCP after SetVCP got %d, %v, want 80", cur, err)
+	}
+	if _, _, err := m.GetVCP(0x60); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("unsupported feature got %v, want ErrUnsupported", err)
+	}
+	caps, err := m.Capabilities()
+	if err != nil || caps != ci.caps {
+		t.Errorf("Capabilities got %q, %v, want %q", caps, err, ci.caps)
+	}
+	// Get, set, get, get and three capability fragments.
+	want := []time.Duration{getDelay, setDelay, getDelay, getDelay, setDelay, setDelay}
+	if len(ci.gaps) != len(want) {
+		t.Fatalf("got %d gaps between commands, want %d", len(ci.gaps), len(want))
+	}
+	for i, g := range ci.gaps {
+		if g < want[i] {
+			t.Errorf("command %d sent %v after the previous, want at least %v", i+1, g, want[i])
+		}
+	}
+}
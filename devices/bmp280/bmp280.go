@@ -0,0 +1,206 @@
+// Package bmp280 drives a Bosch BMP280 pressure and temperature
+// sensor over i2c.
+//
+// The BMP280 datasheet, which describes the registers and the
+// compensation formulas used here, is:
+//
+//	https://www.bosch-sensortec.com/media/boschsensortec/downloads/datasheets/bst-bmp280-ds001.pdf
+package bmp280 // zappem.net/pub/io/i2c/devices/bmp280
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"zappem.net/pub/io/i2c"
+)
+
+// Addr and AltAddr are the i2c addresses of the BMP280, selected by
+// the level of its SDO pin.
+const (
+	Addr    = 0x76
+	AltAddr = 0x77
+)
+
+// ChipID is the content of the RegID register of a BMP280.
+const ChipID = 0x58
+
+// RegCalib etc are the BMP280 registers used by this package.
+const (
+	RegCalib    = 0x88
+	RegID       = 0xd0
+	RegStatus   = 0xf3
+	RegCtrlMeas = 0xf4
+	RegData     = 0xf7
+)
+
+// statusMeasuring is set in RegStatus while a conversion is running.
+const statusMeasuring = 0x08
+
+// forcedX1 requests a single forced mode conversion of temperature and
+// pressure, each without oversampling.
+const forcedX1 = 1<<5 | 1<<2 | 0x01
+
+// ErrChip indicates the device is not a BMP280.
+var ErrChip = errors.New("not a BMP280")
+
+// Coefficients holds the factory calibration of a BMP280, in the
+// order of its calibration registers.
+type Coefficients struct {
+	T1     uint16
+	T2, T3 int16
+	P1     uint16
+	P2, P3 int16
+	P4, P5 int16
+	P6, P7 int16
+	P8, P9 int16
+}
+
+// DecodeCoefficients decodes the 24 bytes of the calibration
+// registers, starting at RegCalib.
+func DecodeCoefficients(d []byte) (*Coefficients, error) {
+	cal := &Coefficients{}
+	if len(d) < binary.Size(cal) {
+		return nil, i2c.ErrTruncated
+	}
+	if err := binary.Read(bytes.NewReader(d), binary.LittleEndian, cal); err != nil {
+		return nil, err
+	}
+	return cal, nil
+}
+
+// Temperature compensates a raw temperature reading, returning the
+// temperature in degrees Celsius and the fine resolution temperature
+// needed by Pressure.
+func (cal *Coefficients) Temperature(adcT int32) (celsius, tFine float64) {
+	t, t1 := float64(adcT), float64(cal.T1)
+	v1 := (t/16384 - t1/1024) * float64(cal.T2)
+	v2 := (t/131072 - t1/8192) * (t/131072 - t1/8192) * float64(cal.T3)
+	tFine = v1 + v2
+	return tFine / 5120, tFine
+}
+
+// Pressure compensates a raw pressure reading, returning the pressure
+// in Pa. The tFine value is that returned by Temperature for the
+// accompanying temperature reading.
+func (cal *Coefficients) Pressure(adcP int32, tFine float64) float64 {
+	v1 := tFine/2 - 64000
+	v2 := v1 * v1 * float64(cal.P6) / 32768
+	v2 += v1 * float64(cal.P5) * 2
+	v2 = v2/4 + float64(cal.P4)*65536
+	v1 = (float64(cal.P3)*v1*v1/524288 + float64(cal.P2)*v1) / 524288
+	v1 = (1 + v1/32768) * float64(cal.P1)
+	if v1 == 0 {
+		return 0
+	}
+	p := 1048576 - float64(adcP)
+	p = (p - v2/4096) * 6250 / v1
+	v1 = float64(cal.P9) * p * p / 2147483648
+	v2 = p * float64(cal.P8) / 32768
+	return p + (v1+v2+float64(cal.P7))/16
+}
+
+// Device holds a connection to a BMP280.
+type Device struct {
+	c   *i2c.Conn
+	cal *Coefficients
+
+	// Timeout bounds how long to wait for a conversion.
+	Timeout time.Duration
+}
+
+// New returns a Device that talks to a BMP280 over c.
+func New(c *i2c.Conn) *Device {
+	return &Device{c: c, Timeout: 100 * time.Millisecond}
+}
+
+// Open connects to the BMP280 at addr on the named bus device file,
+// and confirms its chip ID.
+func Open(bus string, addr uint) (*Device, error) {
+	c, err := i2c.NewConn(bus, addr, false, binary.LittleEndian)
+	if err != nil {
+		return nil, err
+	}
+	d := New(c)
+	id, err := d.ID()
+	if err == nil && id != ChipID {
+		err = fmt.Errorf("%w: chip ID %02xh", ErrChip, id)
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// Close closes the device's connection.
+func (d *Device) Close() error {
+	return d.c.Close()
+}
+
+// ID reads the chip ID, which is ChipID for a BMP280.
+func (d *Device) ID() (byte, error) {
+	var id [1]byte
+	if _, err := d.c.Regs(RegID, id[:]); err != nil {
+		return 0, err
+	}
+	return id[0], nil
+}
+
+// ReadCoefficients reads the device's calibration, which is retained
+// for compensating later readings.
+func (d *Device) ReadCoefficients() (*Coefficients, error) {
+	buf := make([]byte, binary.Size(Coefficients{}))
+	if _, err := d.c.Regs(RegCalib, buf); err != nil {
+		return nil, err
+	}
+	cal, err := DecodeCoefficients(buf)
+	if err != nil {
+		return nil, err
+	}
+	d.cal = cal
+	return cal, nil
+}
+
+// measure performs a forced mode conversion and returns the raw
+// pressure and temperature readings.
+func (d *Device) measure() (adcP, adcT int32, err error) {
+	if d.cal == nil {
+		if _, err := d.ReadCoefficients(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := d.c.SetReg(RegCtrlMeas, forcedX1); err != nil {
+		return 0, 0, err
+	}
+	if err := d.c.WaitRegBit(RegStatus, statusMeasuring, 0, time.Millisecond, d.Timeout); err != nil {
+		return 0, 0, err
+	}
+	var r [6]byte
+	if _, err := d.c.Regs(RegData, r[:]); err != nil {
+		return 0, 0, err
+	}
+	adcP = int32(r[0])<<12 | int32(r[1])<<4 | int32(r[2])>>4
+	adcT = int32(r[3])<<12 | int32(r[4])<<4 | int32(r[5])>>4
+	return adcP, adcT, nil
+}
+
+// Read performs a conversion and returns the compensated temperature,
+// in degrees Celsius, and pressure, in Pa.
+func (d *Device) Read() (celsius, pascals float64, err error) {
+	adcP, adcT, err := d.measure()
+	if err != nil {
+		return 0, 0, err
+	}
+	celsius, tFine := d.cal.Temperature(adcT)
+	return celsius, d.cal.Pressure(adcP, tFine), nil
+}
+
+// ReadPressure performs a conversion and returns the compensated
+// pressure in Pa.
+func (d *Device) ReadPressure() (float64, error) {
+	_, p, err := d.Read()
+	return p, err
+}
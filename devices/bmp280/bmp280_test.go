@@ -0,0 +1,57 @@
+package bmp280
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"zappem.net/pub/io/i2c"
+)
+
+// datasheet holds the calibration of the worked example in section
+// 3.12 of the BMP280 datasheet.
+var datasheet = Coefficients{
+	T1: 27504, T2: 26435, T3: -1000,
+	P1: 36477, P2: -10685, P3: 3024,
+	P4: 2855, P5: 140, P6: -7,
+	P7: 15500, P8: -14600, P9: 6000,
+}
+
+func TestCompensation(t *testing.T) {
+	celsius, tFine := datasheet.Temperature(519888)
+	if math.Abs(celsius-25.08) > 0.005 {
+		t.Errorf("got %.4f C, want 25.08 C", celsius)
+	}
+	if math.Abs(tFine-128422.2869) > 0.001 {
+		t.Errorf("got t_fine %.4f, want 128422.2869", tFine)
+	}
+	if p := datasheet.Pressure(415148, tFine); math.Abs(p-100653.27) > 0.005 {
+		t.Errorf("got %.4f Pa, want 100653.27 Pa", p)
+	}
+	var zero Coefficients
+	if p := zero.Pressure(415148, tFine); p != 0 {
+		t.Errorf("uncalibrated got %v Pa, want 0", p)
+	}
+}
+
+func TestDecodeCoefficients(t *testing.T) {
+	d := make([]byte, 0, 24)
+	for _, v := range []int16{
+		int16(datasheet.T1), datasheet.T2, datasheet.T3,
+		int16(datasheet.P1), datasheet.P2, datasheet.P3,
+		datasheet.P4, datasheet.P5, datasheet.P6,
+		datasheet.P7, datasheet.P8, datasheet.P9,
+	} {
+		d = binary.LittleEndian.AppendUint16(d, uint16(v))
+	}
+	cal, err := DecodeCoefficients(d)
+	if err != nil {
+		t.Fatalf("DecodeCoefficients failed: %v", err)
+	}
+	if *cal != datasheet {
+		t.Errorf("got %+v, want %+v", *cal, datasheet)
+	}
+	if _, err := DecodeCoefficients(d[:23]); err != i2c.ErrTruncated {
+		t.Errorf("short calibration got %v, want ErrTruncated", err)
+	}
+}
@@ -0,0 +1,273 @@
+// Package pn532 drives an NXP PN532 NFC controller over i2c.
+//
+// The PN532 user manual, which describes the frame format and the
+// commands used here, is:
+//
+//	https://www.nxp.com/docs/en/user-guide/141520.pdf
+package pn532 // zappem.net/pub/io/i2c/devices/pn532
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"zappem.net/pub/io/i2c"
+)
+
+// Addr is the i2c address of the PN532.
+const Addr = 0x24
+
+// hostTFI and deviceTFI are the frame identifiers of frames sent to,
+// and received from, the PN532.
+const (
+	hostTFI   = 0xd4
+	deviceTFI = 0xd5
+)
+
+// GetFirmwareVersion etc are the PN532 command codes used by this
+// package.
+const (
+	GetFirmwareVersion  = 0x02
+	SAMConfiguration    = 0x14
+	InListPassiveTarget = 0x4a
+)
+
+// ErrFrame etc are errors reported by the package.
+var (
+	ErrFrame    = errors.New("malformed frame")
+	ErrChecksum = errors.New("frame checksum mismatch")
+	ErrNack     = errors.New("command not acknowledged")
+	ErrNoTarget = errors.New("no target found")
+)
+
+// Ack and Nack are the acknowledge frames.
+var (
+	Ack  = []byte{0x00, 0x00, 0xff, 0x00, 0xff, 0x00}
+	Nack = []byte{0x00, 0x00, 0xff, 0xff, 0x00, 0x00}
+)
+
+// EncodeFrame returns the normal information frame carrying command
+// cmd and its parameters from the host to the PN532.
+func EncodeFrame(cmd byte, params []byte) ([]byte, error) {
+	n := len(params) + 2
+	if n > 0xff {
+		return nil, fmt.Errorf("%w: %d bytes is too long for a normal frame", ErrFrame, n)
+	}
+	d := make([]byte, 0, n+7)
+	d = append(d, 0x00, 0x00, 0xff, byte(n), byte(-n), hostTFI, cmd)
+	d = append(d, params...)
+	sum := hostTFI + cmd
+	for _, b := range params {
+		sum += b
+	}
+	return append(d, -sum, 0x00), nil
+}
+
+// DecodeFrame validates a normal information frame sent by the PN532
+// and returns its data, starting with the response code. Leading
+// zero bytes before the start code are skipped. A NACK frame yields
+// ErrNack, and an ACK frame, which carries no data, ErrFrame.
+func DecodeFrame(d []byte) ([]byte, error) {
+	i := bytes.Index(d, []byte{0x00, 0xff})
+	if i < 0 || len(d) < i+5 {
+		return nil, ErrFrame
+	}
+	d = d[i+2:]
+	n, lcs := d[0], d[1]
+	switch {
+	case n == 0x00 && lcs == 0xff:
+		return nil, fmt.Errorf("%w: ACK frame", ErrFrame)
+	case n == 0xff && lcs == 0x00:
+		return nil, ErrNack
+	}
+	if n+lcs != 0 {
+		return nil, fmt.Errorf("length %w", ErrChecksum)
+	}
+	if n < 1 || len(d) < int(n)+3 {
+		return nil, fmt.Errorf("%w: length %d", ErrFrame, n)
+	}
+	body := d[2 : 2+int(n)]
+	if body[0] != deviceTFI {
+		return nil, fmt.Errorf("%w: TFI %02xh", ErrFrame, body[0])
+	}
+	sum := d[2+int(n)]
+	for _, b := range body {
+		sum += b
+	}
+	if sum != 0 {
+		return nil, fmt.Errorf("data %w", ErrChecksum)
+	}
+	return body[1:], nil
+}
+
+// Device holds a connection to a PN532.
+type Device struct {
+	mu sync.Mutex
+	rw io.ReadWriter
+
+	// IRQ, if set, reports whether the PN532's IRQ line is
+	// asserted (low). When it is provided, the driver waits for
+	// the line rather than polling the PN532's status byte.
+	IRQ func() bool
+
+	// Timeout bounds how long to wait for the PN532 to respond.
+	Timeout time.Duration
+}
+
+// New returns a Device that talks to a PN532 over rw.
+func New(rw io.ReadWriter) *Device {
+	return &Device{rw: rw, Timeout: time.Second}
+}
+
+// Open connects to a PN532 on the named bus device file. The caller
+// should Close the returned connection when done.
+func Open(bus string) (*Device, *i2c.Conn, error) {
+	c, err := i2c.NewConn(bus, Addr, false, binary.BigEndian)
+	if err != nil {
+		return nil, nil, err
+	}
+	return New(c), c, nil
+}
+
+// waitReady waits for the PN532 to indicate it has data for the host.
+// Over i2c, every read starts with a status byte whose low bit
+// indicates readiness.
+func (d *Device) waitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	status := make([]byte, 1)
+	for {
+		if d.IRQ != nil {
+			if d.IRQ() {
+				return nil
+			}
+		} else if n, err := d.rw.Read(status); err == nil && n == 1 && status[0]&1 != 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return i2c.ErrTimeout
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// read waits for the PN532 to be ready and reads n bytes, discarding
+// the leading status byte.
+func (d *Device) read(n int, timeout time.Duration) ([]byte, error) {
+	if err := d.waitReady(timeout); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n+1)
+	if j, err := d.rw.Read(buf); err != nil {
+		return nil, err
+	} else if j != len(buf) {
+		return nil, i2c.ErrTruncated
+	}
+	return buf[1:], nil
+}
+
+// Call sends command cmd with params to the PN532, waits for it to be
+// acknowledged, and returns the data of the response, which is
+// expected to hold no more than maxResp bytes after the response
+// code. Command timeout, if longer than d.Timeout, bounds the wait
+// for the response.
+func (d *Device) Call(cmd byte, params []byte, maxResp int, timeout time.Duration) ([]byte, error) {
+	frame, err := EncodeFrame(cmd, params)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n, err := d.rw.Write(frame); err != nil {
+		return nil, err
+	} else if n != len(frame) {
+		return nil, i2c.ErrTruncated
+	}
+	ack, err := d.read(len(Ack), d.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(ack, Nack) || !bytes.Equal(ack, Ack) {
+		return nil, ErrNack
+	}
+	if timeout < d.Timeout {
+		timeout = d.Timeout
+	}
+	resp, err := d.read(maxResp+9, timeout)
+	if err != nil {
+		return nil, err
+	}
+	data, err := DecodeFrame(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1 || data[0] != cmd+1 {
+		return nil, fmt.Errorf("%w: response to %02xh", ErrFrame, cmd)
+	}
+	return data[1:], nil
+}
+
+// FirmwareVersion holds the response to GetFirmwareVersion.
+type FirmwareVersion struct {
+	IC       byte
+	Ver, Rev byte
+	Support  byte
+}
+
+// String formats the version, for example "PN532 v1.6".
+func (v FirmwareVersion) String() string {
+	return fmt.Sprintf("PN5%02x v%d.%d", v.IC, v.Ver, v.Rev)
+}
+
+// FirmwareVersion reads the PN532's IC and firmware version.
+func (d *Device) FirmwareVersion() (FirmwareVersion, error) {
+	r, err := d.Call(GetFirmwareVersion, nil, 4, 0)
+	if err != nil {
+		return FirmwareVersion{}, err
+	}
+	if len(r) != 4 {
+		return FirmwareVersion{}, ErrFrame
+	}
+	return FirmwareVersion{IC: r[0], Ver: r[1], Rev: r[2], Support: r[3]}, nil
+}
+
+// SAMConfig configures the PN532's security access module for normal
+// mode, with the IRQ line in use. This is needed before detecting
+// tags.
+func (d *Device) SAMConfig() error {
+	_, err := d.Call(SAMConfiguration, []byte{0x01, 0x14, 0x01}, 0, 0)
+	return err
+}
+
+// Target describes a detected ISO14443A tag.
+type Target struct {
+	SensRes uint16
+	SelRes  byte
+	UID     []byte
+}
+
+// ListPassiveTarget waits up to timeout for an ISO14443A tag at 106
+// kbps to enter the field and returns its details.
+func (d *Device) ListPassiveTarget(timeout time.Duration) (*Target, error) {
+	r, err := d.Call(InListPassiveTarget, []byte{0x01, 0x00}, 32, timeout)
+	if errors.Is(err, i2c.ErrTimeout) {
+		return nil, ErrNoTarget
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(r) < 1 || r[0] == 0 {
+		return nil, ErrNoTarget
+	}
+	if len(r) < 6 || len(r) < 6+int(r[5]) {
+		return nil, ErrFrame
+	}
+	return &Target{
+		SensRes: binary.BigEndian.Uint16(r[2:4]),
+		SelRes:  r[4],
+		UID:     append([]byte(nil), r[6:6+int(r[5])]...),
+	}, nil
+}
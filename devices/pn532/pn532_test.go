@@ -0,0 +1,158 @@
+package pn532
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"zappem.net/pub/io/i2c"
+)
+
+// The frames below are those of the examples in the PN532 user manual.
+
+func TestEncodeFrame(t *testing.T) {
+	vs := []struct {
+		cmd    byte
+		params []byte
+		want   []byte
+	}{
+		{GetFirmwareVersion, nil, []byte{0x00, 0x00, 0xff, 0x02, 0xfe, 0xd4, 0x02, 0x2a, 0x00}},
+		{SAMConfiguration, []byte{0x01, 0x14, 0x01}, []byte{0x00, 0x00, 0xff, 0x05, 0xfb, 0xd4, 0x14, 0x01, 0x14, 0x01, 0x02, 0x00}},
+		{InListPassiveTarget, []byte{0x01, 0x00}, []byte{0x00, 0x00, 0xff, 0x04, 0xfc, 0xd4, 0x4a, 0x01, 0x00, 0xe1, 0x00}},
+	}
+	for i, v := range vs {
+		got, err := EncodeFrame(v.cmd, v.params)
+		if err != nil {
+			t.Errorf("test=%d: EncodeFrame failed: %v", i, err)
+		} else if !bytes.Equal(got, v.want) {
+			t.Errorf("test=%d: got % x, want % x", i, got, v.want)
+		}
+	}
+	if _, err := EncodeFrame(0x40, make([]byte, 254)); !errors.Is(err, ErrFrame) {
+		t.Errorf("oversized frame got %v, want ErrFrame", err)
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	vs := []struct {
+		name  string
+		frame []byte
+		want  []byte
+		err   error
+	}{
+		{"firmware", []byte{0x00, 0x00, 0xff, 0x06, 0xfa, 0xd5, 0x03, 0x32, 0x01, 0x06, 0x07, 0xe8, 0x00}, []byte{0x03, 0x32, 0x01, 0x06, 0x07}, nil},
+		{"sam", []byte{0x00, 0x00, 0xff, 0x02, 0xfe, 0xd5, 0x15, 0x16, 0x00}, []byte{0x15}, nil},
+		{"padded", []byte{0x00, 0x00, 0x00, 0xff, 0x02, 0xfe, 0xd5, 0x15, 0x16, 0x00, 0x00, 0x00}, []byte{0x15}, nil},
+		{"ack", Ack, nil, ErrFrame},
+		{"nack", Nack, nil, ErrNack},
+		{"error", []byte{0x00, 0x00, 0xff, 0x01, 0xff, 0x7f, 0x81, 0x00}, nil, ErrFrame},
+		{"lcs", []byte{0x00, 0x00, 0xff, 0x02, 0xfd, 0xd5, 0x15, 0x16, 0x00}, nil, ErrChecksum},
+		{"dcs", []byte{0x00, 0x00, 0xff, 0x02, 0xfe, 0xd5, 0x15, 0x17, 0x00}, nil, ErrChecksum},
+		{"host", []byte{0x00, 0x00, 0xff, 0x02, 0xfe, 0xd4, 0x02, 0x2a, 0x00}, nil, ErrFrame},
+		{"short", []byte{0x00, 0x00, 0xff, 0x06, 0xfa, 0xd5, 0x03}, nil, ErrFrame},
+		{"empty", []byte{0x00, 0x00, 0x00}, nil, ErrFrame},
+	}
+	for _, v := range vs {
+		got, err := DecodeFrame(v.frame)
+		if v.err != nil {
+			if !errors.Is(err, v.err) {
+				t.Errorf("%s: got %v, want %v", v.name, err, v.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: DecodeFrame failed: %v", v.name, err)
+		} else if !bytes.Equal(got, v.want) {
+			t.Errorf("%s: got % x, want % x", v.name, got, v.want)
+		}
+	}
+}
+
+// fakePN532 simulates the i2c transport of a PN532. Each read starts
+// with the status byte, which is set while a reply is pending.
+type fakePN532 struct {
+	written [][]byte
+	replies [][]byte
+}
+
+func (f *fakePN532) Write(data []byte) (int, error) {
+	f.written = append(f.written, append([]byte(nil), data...))
+	return len(data), nil
+}
+
+func (f *fakePN532) Read(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	if len(f.replies) == 0 {
+		return len(buf), nil
+	}
+	buf[0] = 0x01
+	if len(buf) > 1 {
+		copy(buf[1:], f.replies[0])
+		f.replies = f.replies[1:]
+	}
+	return len(buf), nil
+}
+
+func TestFirmwareVersion(t *testing.T) {
+	f := &fakePN532{replies: [][]byte{
+		Ack,
+		{0x00, 0x00, 0xff, 0x06, 0xfa, 0xd5, 0x03, 0x32, 0x01, 0x06, 0x07, 0xe8, 0x00},
+	}}
+	d := New(f)
+	v, err := d.FirmwareVersion()
+	if err != nil {
+		t.Fatalf("FirmwareVersion failed: %v", err)
+	}
+	if want := (FirmwareVersion{IC: 0x32, Ver: 1, Rev: 6, Support: 7}); v != want {
+		t.Errorf("got %+v, want %+v", v, want)
+	}
+	if got, want := v.String(), "PN532 v1.6"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if want := []byte{0x00, 0x00, 0xff, 0x02, 0xfe, 0xd4, 0x02, 0x2a, 0x00}; len(f.written) != 1 || !bytes.Equal(f.written[0], want) {
+		t.Errorf("wrote % x, want % x", f.written, want)
+	}
+}
+
+func TestCallNack(t *testing.T) {
+	d := New(&fakePN532{replies: [][]byte{Nack}})
+	if err := d.SAMConfig(); !errors.Is(err, ErrNack) {
+		t.Errorf("got %v, want ErrNack", err)
+	}
+	d = New(&fakePN532{})
+	d.Timeout = 5 * time.Millisecond
+	if err := d.SAMConfig(); !errors.Is(err, i2c.ErrTimeout) {
+		t.Errorf("silent PN532 got %v, want ErrTimeout", err)
+	}
+}
+
+func TestListPassiveTarget(t *testing.T) {
+	f := &fakePN532{replies: [][]byte{
+		Ack,
+		{0x00, 0x00, 0xff, 0x0c, 0xf4, 0xd5, 0x4b, 0x01, 0x01, 0x00, 0x04, 0x08, 0x04, 0xde, 0xad, 0xbe, 0xef, 0x96, 0x00},
+	}}
+	d := New(f)
+	irqs := 0
+	d.IRQ = func() bool {
+		irqs++
+		return len(f.replies) != 0
+	}
+	tg, err := d.ListPassiveTarget(time.Second)
+	if err != nil {
+		t.Fatalf("ListPassiveTarget failed: %v", err)
+	}
+	if tg.SensRes != 0x0004 || tg.SelRes != 0x08 || !bytes.Equal(tg.UID, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("got %+v", tg)
+	}
+	if irqs != 2 {
+		t.Errorf("IRQ line checked %d times, want 2", irqs)
+	}
+
+	f.replies = [][]byte{Ack, {0x00, 0x00, 0xff, 0x03, 0xfd, 0xd5, 0x4b, 0x00, 0xe0, 0x00}}
+	if _, err := d.ListPassiveTarget(time.Second); err != ErrNoTarget {
+		t.Errorf("empty field got %v, want ErrNoTarget", err)
+	}
+}
@@ -0,0 +1,9 @@
+package i2c
+
+import "syscall"
+
+// absentErrnos are the errors adapters report for a transaction that
+// no device acknowledges.
+var absentErrnos = []syscall.Errno{
+	syscall.ENXIO, syscall.EREMOTEIO, syscall.EIO, syscall.ETIMEDOUT, syscall.EAGAIN,
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package i2c
+
+import "syscall"
+
+// absentErrnos are the errors adapters report for a transaction that
+// no device acknowledges. EREMOTEIO is specific to Linux.
+var absentErrnos = []syscall.Errno{
+	syscall.ENXIO, syscall.EIO, syscall.ETIMEDOUT, syscall.EAGAIN,
+}
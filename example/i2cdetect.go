@@ -0,0 +1,77 @@
+// Program i2cdetect is an example that scans an i2c bus for devices
+// in the manner of the i2c-tools program of the same name.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"zappem.net/pub/io/i2c"
+)
+
+var (
+	bus     = flag.Uint("bus", 1, "i2c bus number to scan")
+	quick   = flag.Bool("q", false, "probe all addresses with a quick write")
+	read    = flag.Bool("r", false, "probe all addresses with a receive byte")
+	all     = flag.Bool("a", false, "scan all addresses, 0x00-0x7f")
+	exclude = flag.String("x", "", "comma separated addresses never to touch")
+)
+
+func main() {
+	flag.Parse()
+	if *quick && *read {
+		log.Fatal("-q and -r are mutually exclusive")
+	}
+	policy := &i2c.ScanPolicy{}
+	if *quick {
+		policy.Method = i2c.ProbeQuick
+	} else if *read {
+		policy.Method = i2c.ProbeRead
+	}
+	if *all {
+		policy.First, policy.Last = 0x00, 0x7f
+	}
+	if *exclude != "" {
+		for _, a := range strings.Split(*exclude, ",") {
+			v, err := strconv.ParseUint(strings.TrimSpace(a), 0, 7)
+			if err != nil {
+				log.Fatalf("bad -x address %q: %v", a, err)
+			}
+			policy.Exclude = append(policy.Exclude, uint(v))
+		}
+	}
+
+	found, err := i2c.Scan(i2c.BusFile(*bus), policy)
+	if err != nil {
+		log.Fatalf("scan of bus %d failed: %v", *bus, err)
+	}
+	hits := make(map[uint]i2c.ScanResult)
+	for _, r := range found {
+		hits[r.Addr] = r
+	}
+
+	fmt.Println("     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f")
+	for row := uint(0); row < 0x80; row += 0x10 {
+		fmt.Printf("%02x:", row)
+		for addr := row; addr < row+0x10; addr++ {
+			r, ok := hits[addr]
+			switch {
+			case r.Busy:
+				fmt.Print(" UU")
+			case ok:
+				fmt.Printf(" %02x", addr)
+			default:
+				fmt.Print(" --")
+			}
+		}
+		fmt.Println()
+	}
+	for _, r := range found {
+		if !r.Busy {
+			log.Printf("device @ %02xh found with %v probe", r.Addr, r.Method)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Program pn532 is an example that polls a PN532 NFC controller for
+// ISO14443A tags and logs their UIDs. If the PN532's IRQ pin is wired
+// to a GPIO, pass the GPIO's sysfs value file, for example
+// /sys/class/gpio/gpio17/value, with --irq to wait on it rather than
+// on the PN532's status byte.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"zappem.net/pub/io/i2c"
+	"zappem.net/pub/io/i2c/devices/pn532"
+)
+
+var (
+	bus      = flag.Uint("bus", 1, "i2c bus number of the PN532")
+	duration = flag.Duration("watch", time.Minute, "time to poll for tags")
+	irq      = flag.String("irq", "", "sysfs value file of the GPIO wired to the IRQ pin")
+)
+
+// irqLine returns a function reporting whether the (active low) IRQ
+// line read from the GPIO value file is asserted.
+func irqLine(name string) (func() bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1)
+	return func() bool {
+		n, err := f.ReadAt(buf, 0)
+		return err == nil && n == 1 && buf[0] == '0'
+	}, nil
+}
+
+func main() {
+	flag.Parse()
+	d, c, err := pn532.Open(i2c.BusFile(*bus))
+	if err != nil {
+		log.Fatalf("failed to open PN532: %v", err)
+	}
+	defer c.Close()
+	if *irq != "" {
+		if d.IRQ, err = irqLine(*irq); err != nil {
+			log.Fatalf("failed to open IRQ line: %v", err)
+		}
+	}
+
+	v, err := d.FirmwareVersion()
+	if err != nil {
+		log.Fatalf("failed to read firmware version: %v", err)
+	}
+	log.Printf("found %v", v)
+	if err := d.SAMConfig(); err != nil {
+		log.Fatalf("failed to configure SAM: %v", err)
+	}
+
+	var last string
+	target := time.Now().Add(*duration)
+	for time.Now().Before(target) {
+		t, err := d.ListPassiveTarget(time.Second)
+		if err == pn532.ErrNoTarget {
+			last = ""
+			continue
+		}
+		if err != nil {
+			log.Printf("poll failed: %v", err)
+			continue
+		}
+		if uid := string(t.UID); uid != last {
+			log.Printf("tag UID=% x SENS_RES=%04x SEL_RES=%02x", t.UID, t.SensRes, t.SelRes)
+			last = uid
+		}
+	}
+}
@@ -0,0 +1,446 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// fakeDev simulates a device with 256 byte registers and an auto
+// incrementing register pointer, as most i2c sensors and EEPROMs
+// have.
+type fakeDev struct {
+	regs [256]byte
+	ptr  byte
+
+	// blocks holds the SMBus block values of the device, by
+	// command.
+	blocks map[byte][]byte
+
+	// nak makes the device acknowledge nothing.
+	nak bool
+	// short, if positive, limits the bytes of each plain read or
+	// write. If negative, they transfer nothing.
+	short int
+	// nakAfter, if non-zero, is the number of bytes the device
+	// accepts from plain writes before it stops acknowledging them.
+	nakAfter, written int
+	// badPEC makes the device fail packet error checks.
+	badPEC bool
+	// onRead, if set, supplies the data of plain and combined
+	// reads, in place of the registers.
+	onRead func(buf []byte)
+	// onWrite, if set, is called after each plain and combined
+	// write has updated the registers.
+	onWrite func(data []byte)
+}
+
+// write performs a plain write to the device.
+func (d *fakeDev) write(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	d.ptr = data[0]
+	for _, b := range data[1:] {
+		d.regs[d.ptr] = b
+		d.ptr++
+	}
+	if d.onWrite != nil {
+		d.onWrite(data)
+	}
+}
+
+// read performs a plain read from the device.
+func (d *fakeDev) read(buf []byte) {
+	if d.onRead != nil {
+		d.onRead(buf)
+		return
+	}
+	for i := range buf {
+		buf[i] = d.regs[d.ptr]
+		d.ptr++
+	}
+}
+
+// fakeAdapter simulates a bus adapter and the devices on its bus.
+type fakeAdapter struct {
+	mu    sync.Mutex
+	funcs uint64
+	devs  map[uint]*fakeDev
+	// busy lists addresses claimed by a kernel driver.
+	busy map[uint]bool
+	// files routes plain reads and writes to the opened file, such
+	// as a pipe or temporary file, rather than the devices.
+	files bool
+	// quickErrs are returned, in turn, by quick commands before
+	// they are passed to the device.
+	quickErrs []error
+	// onAddr, if set, is called with the adapter locked each time a
+	// device address is selected.
+	onAddr func(addr uint)
+	// log records the transactions performed.
+	log []string
+	// smbus records the arguments of the last SMBus ioctl.
+	smbus smbusIoctlData
+	// ioctls counts the ioctls performed, by command.
+	ioctls map[uintptr]int
+	// args records the argument of the last ioctl whose argument
+	// is a value, by command.
+	args map[uintptr]uintptr
+}
+
+// newFakeAdapter returns an adapter supporting plain i2c and all of
+// SMBus, with devices at the listed addresses.
+func newFakeAdapter(addrs ...uint) *fakeAdapter {
+	a := &fakeAdapter{
+		funcs:  FUNC_I2C | FUNC_10BIT_ADDR | FUNC_PROTOCOL_MANGLING | FUNC_NOSTART | FUNC_SMBUS_PEC | 0x0fff0000 | FUNC_SMBUS_BLOCK_PROC_CALL,
+		devs:   make(map[uint]*fakeDev),
+		busy:   make(map[uint]bool),
+		ioctls: make(map[uintptr]int),
+		args:   make(map[uintptr]uintptr),
+	}
+	for _, addr := range addrs {
+		a.devs[addr] = &fakeDev{blocks: make(map[byte][]byte)}
+	}
+	return a
+}
+
+// ops returns, and clears, the transaction log.
+func (a *fakeAdapter) ops() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	log := a.log
+	a.log = nil
+	return log
+}
+
+// dev returns the device at addr, or an error for an address that
+// nothing acknowledges. The caller must hold a.mu.
+func (a *fakeAdapter) dev(addr uint) (*fakeDev, error) {
+	d := a.devs[addr]
+	if d == nil || d.nak {
+		return nil, syscall.ENXIO
+	}
+	return d, nil
+}
+
+// fakeFile is the busFile of a connection to a fakeAdapter. Like a
+// bus device file, it holds its own device address.
+type fakeFile struct {
+	a      *fakeAdapter
+	file   *os.File
+	addr   uint
+	tenBit bool
+	pec    bool
+	closed bool
+}
+
+// newFakeConn returns a connection to the device at addr of a, with
+// the byte order endian.
+func newFakeConn(t testing.TB, a *fakeAdapter, addr uint, endian binary.ByteOrder) *Conn {
+	t.Helper()
+	c := &Conn{bus: "/dev/i2c-fake", f: &fakeFile{a: a}, endian: endian}
+	if err := c.setAddr(addr, false); err != nil {
+		t.Fatalf("setAddr(%#x): %v", addr, err)
+	}
+	return c
+}
+
+// newFileConn returns a connection whose reads and writes use f, for
+// example a pipe or temporary file.
+func newFileConn(f *os.File) *Conn {
+	return &Conn{bus: f.Name(), f: &fakeFile{a: newFakeAdapter(), file: f}, endian: binary.BigEndian}
+}
+
+// useFake makes bus device files opened by the package use a, and
+// returns the name of a file, in a temporary directory, to open as
+// the numbered bus.
+func useFake(t testing.TB, a *fakeAdapter, bus uint) string {
+	t.Helper()
+	old := wrapFile
+	wrapFile = func(f *os.File) busFile {
+		ff := &fakeFile{a: a}
+		if a.files {
+			ff.file = f
+		} else {
+			f.Close()
+		}
+		return ff
+	}
+	t.Cleanup(func() { wrapFile = old })
+	name := filepath.Join(t.TempDir(), fmt.Sprintf("i2c-%d", bus))
+	if err := os.WriteFile(name, nil, 0600); err != nil {
+		t.Fatalf("creating %q: %v", name, err)
+	}
+	return name
+}
+
+func (f *fakeFile) Read(data []byte) (int, error) {
+	if f.file != nil {
+		return f.file.Read(data)
+	}
+	a := f.a
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.log = append(a.log, fmt.Sprintf("r %02x: %d", f.addr, len(data)))
+	d, err := a.dev(f.addr)
+	if err != nil {
+		return 0, err
+	}
+	if d.short < 0 {
+		data = nil
+	} else if d.short != 0 && len(data) > d.short {
+		data = data[:d.short]
+	}
+	d.read(data)
+	return len(data), nil
+}
+
+func (f *fakeFile) Write(data []byte) (int, error) {
+	if f.file != nil {
+		return f.file.Write(data)
+	}
+	a := f.a
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.log = append(a.log, fmt.Sprintf("w %02x: % x", f.addr, data))
+	d, err := a.dev(f.addr)
+	if err != nil {
+		return 0, err
+	}
+	if d.short < 0 {
+		data = nil
+	} else if d.short != 0 && len(data) > d.short {
+		data = data[:d.short]
+	}
+	if d.nakAfter != 0 {
+		if d.written >= d.nakAfter {
+			return 0, syscall.ENXIO
+		}
+		d.written += len(data)
+	}
+	d.write(data)
+	return len(data), nil
+}
+
+func (f *fakeFile) Close() error {
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+	if f.file != nil {
+		return f.file.Close()
+	}
+	return nil
+}
+
+func (f *fakeFile) fcntl(cmd, arg uintptr) (int, error) {
+	if f.file == nil {
+		return 0, syscall.ENOTTY
+	}
+	return osFile{f.file}.fcntl(cmd, arg)
+}
+
+func (f *fakeFile) fd() (uintptr, error) {
+	if f.file == nil {
+		return 0, syscall.ENOTTY
+	}
+	return osFile{f.file}.fd()
+}
+
+func (f *fakeFile) ioctl(cmd, arg uintptr) (int, error) {
+	a := f.a
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ioctls[cmd]++
+	a.args[cmd] = arg
+	switch cmd {
+	case TENBIT:
+		if arg != 0 && a.funcs&FUNC_10BIT_ADDR == 0 {
+			return 0, syscall.EINVAL
+		}
+		f.tenBit = arg != 0
+	case SLAVE:
+		if a.busy[uint(arg)] {
+			return 0, syscall.EBUSY
+		}
+		f.addr = uint(arg)
+		if a.onAddr != nil {
+			a.onAddr(f.addr)
+		}
+	case SLAVE_FORCE:
+		f.addr = uint(arg)
+		if a.onAddr != nil {
+			a.onAddr(f.addr)
+		}
+	case PEC:
+		f.pec = arg != 0
+	case RETRIES, TIMEOUT:
+	default:
+		return 0, syscall.ENOTTY
+	}
+	return 0, nil
+}
+
+func (f *fakeFile) ioctlPtr(cmd uintptr, arg unsafe.Pointer) (int, error) {
+	a := f.a
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ioctls[cmd]++
+	switch cmd {
+	case FUNCS:
+		*(*uint)(arg) = uint(a.funcs)
+		return 0, nil
+	case SMBUS:
+		return 0, f.smbus((*smbusIoctlData)(arg))
+	case RDWR:
+		return f.rdwr((*i2cRdwrIoctlData)(arg))
+	}
+	return 0, syscall.ENOTTY
+}
+
+// smbus simulates an SMBus transaction. The caller must hold f.a.mu.
+func (f *fakeFile) smbus(args *smbusIoctlData) error {
+	a := f.a
+	a.smbus = *args
+	rw := "w"
+	if args.readWrite == smbusRead {
+		rw = "r"
+	}
+	pec := ""
+	if f.pec {
+		pec = " pec"
+	}
+	a.log = append(a.log, fmt.Sprintf("smbus %02x: %s %02x size=%d%s", f.addr, rw, args.command, args.size, pec))
+	if args.size == smbusQuick && len(a.quickErrs) != 0 {
+		err := a.quickErrs[0]
+		a.quickErrs = a.quickErrs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	d, err := a.dev(f.addr)
+	if err != nil {
+		return err
+	}
+	if f.pec && d.badPEC && args.size != smbusQuick {
+		return syscall.EBADMSG
+	}
+	data := args.data
+	read := args.readWrite == smbusRead
+	switch args.size {
+	case smbusQuick:
+	case smbusByte:
+		if read {
+			data[0] = d.regs[d.ptr]
+			d.ptr++
+		} else {
+			d.ptr = args.command
+		}
+	case smbusByteData:
+		if read {
+			data[0] = d.regs[args.command]
+		} else {
+			d.regs[args.command] = data[0]
+		}
+	case smbusWordData, smbusProcCall:
+		r := args.command
+		if !read {
+			w := hostEndian.Uint16(data[:2])
+			d.regs[r], d.regs[r+1] = byte(w), byte(w>>8)
+		}
+		if read || args.size == smbusProcCall {
+			hostEndian.PutUint16(data[:2], uint16(d.regs[r])|uint16(d.regs[r+1])<<8)
+		}
+	case smbusBlockData, smbusBlockProcCall:
+		if !read {
+			d.blocks[args.command] = append([]byte(nil), data[1:1+data[0]]...)
+		}
+		if read || args.size == smbusBlockProcCall {
+			b := d.blocks[args.command]
+			data[0] = byte(len(b))
+			copy(data[1:], b)
+		}
+	default:
+		return syscall.EOPNOTSUPP
+	}
+	return nil
+}
+
+// rdwr simulates a combined transaction. The caller must hold f.a.mu.
+func (f *fakeFile) rdwr(args *i2cRdwrIoctlData) (int, error) {
+	a := f.a
+	ms := unsafe.Slice(args.msgs, args.nmsgs)
+	var desc []string
+	for _, m := range ms {
+		fl := MsgFlag(m.flags)
+		s := fmt.Sprintf("%02x r %d", m.addr, m.len)
+		if fl&M_RD == 0 {
+			s = fmt.Sprintf("%02x w % x", m.addr, unsafe.Slice(m.buf, m.len))
+		}
+		if fl &^= M_RD; fl != 0 {
+			s += " " + fl.String()
+		}
+		desc = append(desc, s)
+	}
+	a.log = append(a.log, "rdwr "+strings.Join(desc, "; "))
+	for _, m := range ms {
+		d, err := a.dev(uint(m.addr))
+		if err != nil {
+			if MsgFlag(m.flags)&M_IGNORE_NAK != 0 {
+				continue
+			}
+			return 0, err
+		}
+		buf := unsafe.Slice(m.buf, m.len)
+		switch {
+		case MsgFlag(m.flags)&M_RECV_LEN != 0:
+			b := d.blocks[d.ptr]
+			buf[0] = byte(len(b))
+			copy(buf[1:], b)
+		case MsgFlag(m.flags)&M_RD != 0:
+			d.read(buf)
+		default:
+			d.write(buf)
+		}
+	}
+	return len(ms), nil
+}
+
+// fakeClock simulates the passage of time: sleeping advances it.
+type fakeClock struct {
+	mu     sync.Mutex
+	t      time.Time
+	sleeps []time.Duration
+}
+
+// useClock makes the package use a fake clock, which it returns.
+func useClock(t testing.TB) *fakeClock {
+	clk := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	oldNow, oldSleep := now, sleep
+	now, sleep = clk.now, clk.sleep
+	t.Cleanup(func() { now, sleep = oldNow, oldSleep })
+	return clk
+}
+
+func (clk *fakeClock) now() time.Time {
+	clk.mu.Lock()
+	defer clk.mu.Unlock()
+	return clk.t
+}
+
+func (clk *fakeClock) sleep(d time.Duration) {
+	clk.mu.Lock()
+	defer clk.mu.Unlock()
+	clk.sleeps = append(clk.sleeps, d)
+	if d > 0 {
+		clk.t = clk.t.Add(d)
+	}
+}
@@ -0,0 +1,91 @@
+package i2c
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// busFile is the open bus device file of a connection. All of the
+// reads, writes and ioctls of a connection go through it.
+type busFile interface {
+	Read(data []byte) (int, error)
+	Write(data []byte) (int, error)
+	Close() error
+
+	// ioctl performs an ioctl whose argument is a value, and
+	// returns the non-negative value the ioctl returns on success.
+	ioctl(cmd, arg uintptr) (int, error)
+	// ioctlPtr performs an ioctl whose argument is a pointer to a
+	// structure, as for ioctl.
+	ioctlPtr(cmd uintptr, arg unsafe.Pointer) (int, error)
+	// fcntl performs an fcntl operation.
+	fcntl(cmd, arg uintptr) (int, error)
+	// fd returns the file descriptor number.
+	fd() (uintptr, error)
+}
+
+// osFile is a busFile for an opened device file.
+type osFile struct {
+	*os.File
+}
+
+// wrapFile adapts an opened bus device file for use by a connection.
+// Tests replace it to simulate an adapter.
+var wrapFile = func(f *os.File) busFile {
+	return osFile{f}
+}
+
+// syscall performs the system call trap on the file's descriptor,
+// with arguments a1 and a2.
+func (f osFile) syscall(trap, a1, a2 uintptr) (int, error) {
+	sc, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var r uintptr
+	sc.Control(func(fd uintptr) {
+		var eno syscall.Errno
+		r, _, eno = syscall.Syscall(trap, fd, a1, a2)
+		if eno != 0 {
+			err = eno
+		}
+	})
+	return int(r), err
+}
+
+func (f osFile) ioctl(cmd, arg uintptr) (int, error) {
+	return f.syscall(syscall.SYS_IOCTL, cmd, arg)
+}
+
+func (f osFile) ioctlPtr(cmd uintptr, arg unsafe.Pointer) (int, error) {
+	sc, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var r uintptr
+	sc.Control(func(fd uintptr) {
+		var eno syscall.Errno
+		r, _, eno = syscall.Syscall(syscall.SYS_IOCTL, fd, cmd, uintptr(arg))
+		if eno != 0 {
+			err = eno
+		}
+	})
+	return int(r), err
+}
+
+func (f osFile) fcntl(cmd, arg uintptr) (int, error) {
+	return f.syscall(syscall.SYS_FCNTL, cmd, arg)
+}
+
+func (f osFile) fd() (uintptr, error) {
+	sc, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var n uintptr
+	err = sc.Control(func(fd uintptr) {
+		n = fd
+	})
+	return n, err
+}
@@ -0,0 +1,69 @@
+package i2c
+
+import "unsafe"
+
+// FUNC_I2C etc are the adapter functionality bits from
+// /usr/include/linux/i2c.h, as reported by Funcs.
+const (
+	FUNC_I2C                    = 0x00000001
+	FUNC_10BIT_ADDR             = 0x00000002
+	FUNC_PROTOCOL_MANGLING      = 0x00000004
+	FUNC_SMBUS_PEC              = 0x00000008
+	FUNC_NOSTART                = 0x00000010
+	FUNC_SLAVE                  = 0x00000020
+	FUNC_SMBUS_BLOCK_PROC_CALL  = 0x00008000
+	FUNC_SMBUS_QUICK            = 0x00010000
+	FUNC_SMBUS_READ_BYTE        = 0x00020000
+	FUNC_SMBUS_WRITE_BYTE       = 0x00040000
+	FUNC_SMBUS_READ_BYTE_DATA   = 0x00080000
+	FUNC_SMBUS_WRITE_BYTE_DATA  = 0x00100000
+	FUNC_SMBUS_READ_WORD_DATA   = 0x00200000
+	FUNC_SMBUS_WRITE_WORD_DATA  = 0x00400000
+	FUNC_SMBUS_PROC_CALL        = 0x00800000
+	FUNC_SMBUS_READ_BLOCK_DATA  = 0x01000000
+	FUNC_SMBUS_WRITE_BLOCK_DATA = 0x02000000
+	FUNC_SMBUS_READ_I2C_BLOCK   = 0x04000000
+	FUNC_SMBUS_WRITE_I2C_BLOCK  = 0x08000000
+	FUNC_SMBUS_HOST_NOTIFY      = 0x10000000
+
+	FUNC_SMBUS_BYTE       = FUNC_SMBUS_READ_BYTE | FUNC_SMBUS_WRITE_BYTE
+	FUNC_SMBUS_BYTE_DATA  = FUNC_SMBUS_READ_BYTE_DATA | FUNC_SMBUS_WRITE_BYTE_DATA
+	FUNC_SMBUS_WORD_DATA  = FUNC_SMBUS_READ_WORD_DATA | FUNC_SMBUS_WRITE_WORD_DATA
+	FUNC_SMBUS_BLOCK_DATA = FUNC_SMBUS_READ_BLOCK_DATA | FUNC_SMBUS_WRITE_BLOCK_DATA
+	FUNC_SMBUS_I2C_BLOCK  = FUNC_SMBUS_READ_I2C_BLOCK | FUNC_SMBUS_WRITE_I2C_BLOCK
+)
+
+// Funcs returns the functionality bitmask, a combination of the
+// FUNC_* bits, of the adapter of the connection's bus. An adapter's
+// functionality is fixed, so it is only queried once per connection,
+// making this cheap enough to consult before each transaction.
+func (c *Conn) Funcs() (uint64, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	return c.adapterFuncs()
+}
+
+// adapterFuncs returns the functionality bitmask of the adapter,
+// caching it in c. The caller must hold c.mu.
+func (c *Conn) adapterFuncs() (uint64, error) {
+	if c.funcsKnown {
+		return c.funcs, nil
+	}
+	// The kernel reports an unsigned long, which matches uint.
+	var funcs uint
+	if err := c.ioctlPtr(FUNCS, unsafe.Pointer(&funcs)); err != nil {
+		return 0, err
+	}
+	c.funcs, c.funcsKnown = uint64(funcs), true
+	return c.funcs, nil
+}
+
+// Supports indicates whether the adapter of the connection's bus
+// supports all of the FUNC_* bits of flag. It is false if the
+// functionality of the adapter cannot be determined.
+func (c *Conn) Supports(flag uint64) bool {
+	funcs, err := c.Funcs()
+	return err == nil && funcs&flag == flag
+}
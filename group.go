@@ -0,0 +1,151 @@
+package i2c
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Group holds a set of connections, typically to identical devices,
+// to which the same register writes are applied. Members on the same
+// bus are always accessed one after another.
+type Group struct {
+	conns []*Conn
+
+	// Parallel bounds how many buses are accessed concurrently.
+	// Values less than 2 access all members sequentially.
+	Parallel int
+
+	// FailFast stops the group operation from starting on further
+	// members once one has failed. By default, a best effort is
+	// made to apply the operation to every member.
+	FailFast bool
+}
+
+// NewGroup returns a group of the listed connections.
+func NewGroup(conns ...*Conn) *Group {
+	return &Group{conns: append([]*Conn(nil), conns...)}
+}
+
+// member returns a name for a group member, for use in errors.
+func member(c *Conn) string {
+	if c == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%s@%02xh", c.bus, c.addr)
+}
+
+// apply invokes fn for each member of the group and returns the
+// joined errors of the failing members.
+func (g *Group) apply(fn func(*Conn) error) error {
+	var buses []string
+	members := make(map[string][]int)
+	for i, c := range g.conns {
+		bus := ""
+		if c != nil {
+			bus = c.bus
+		}
+		if _, ok := members[bus]; !ok {
+			buses = append(buses, bus)
+		}
+		members[bus] = append(members[bus], i)
+	}
+	par := g.Parallel
+	if par < 1 {
+		par = 1
+	}
+
+	var (
+		mu     sync.Mutex
+		failed bool
+		wg     sync.WaitGroup
+	)
+	errs := make([]error, len(g.conns))
+	sem := make(chan struct{}, par)
+	for _, bus := range buses {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(indices []int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			for _, i := range indices {
+				mu.Lock()
+				stop := g.FailFast && failed
+				mu.Unlock()
+				if stop {
+					return
+				}
+				c := g.conns[i]
+				if err := fn(c); err != nil {
+					mu.Lock()
+					errs[i] = fmt.Errorf("%s: %w", member(c), err)
+					failed = true
+					mu.Unlock()
+				}
+			}
+		}(members[bus])
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// WriteReg writes val to register reg of every group member.
+func (g *Group) WriteReg(reg, val byte) error {
+	return g.WriteRegs(reg, []byte{val})
+}
+
+// WriteRegs writes vals, starting at register reg, to every group
+// member.
+func (g *Group) WriteRegs(reg byte, vals []byte) error {
+	return g.apply(func(c *Conn) error {
+		if err := c.lock(); err != nil {
+			return err
+		}
+		defer c.mu.Unlock()
+		return c.writeRegs(reg, vals)
+	})
+}
+
+// UpdateReg replaces the mask selected bits of register reg with
+// those of val on every group member. Each member's read-modify-write
+// is performed without releasing its connection.
+func (g *Group) UpdateReg(reg, mask, val byte) error {
+	return g.apply(func(c *Conn) error {
+		if err := c.lock(); err != nil {
+			return err
+		}
+		defer c.mu.Unlock()
+		_, err := c.updateReg(reg, mask, val)
+		return err
+	})
+}
+
+// WriteAll opens the bus device file once and writes data to each of
+// the 7-bit addresses in addrs, for example to configure an array of
+// identical devices. Failures to reach individual devices do not stop
+// the others being written, and are reported in the returned map,
+// which holds an entry for every address. The returned error is only
+// non-nil if the bus could not be opened.
+func WriteAll(bus string, addrs []uint, data []byte) (map[uint]error, error) {
+	c, err := openBus(bus)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	results := make(map[uint]error, len(addrs))
+	for _, addr := range addrs {
+		if err := c.setAddr(addr, false); err != nil {
+			results[addr] = err
+			continue
+		}
+		n, err := c.write(data)
+		if err == nil && n != len(data) {
+			err = ErrTruncated
+		}
+		results[addr] = err
+	}
+	return results, nil
+}
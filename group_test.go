@@ -0,0 +1,147 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// groupBuses returns a group of two devices, at 0x40 and 0x41, on each
+// of three fake buses, with the first device of the second bus not
+// acknowledging. The adapters are also returned.
+func groupBuses(t *testing.T) (*Group, []*fakeAdapter) {
+	var as []*fakeAdapter
+	var conns []*Conn
+	for bus := 1; bus <= 3; bus++ {
+		a := newFakeAdapter(0x40, 0x41)
+		as = append(as, a)
+		for _, addr := range []uint{0x40, 0x41} {
+			c := newFakeConn(t, a, addr, binary.BigEndian)
+			c.bus = fmt.Sprintf("/dev/i2c-%d", bus)
+			conns = append(conns, c)
+		}
+	}
+	as[1].devs[0x40].nak = true
+	return NewGroup(conns...), as
+}
+
+// written reports which members of groupBuses hold the value written.
+func written(as []*fakeAdapter, reg, val byte) []bool {
+	var w []bool
+	for _, a := range as {
+		for _, addr := range []uint{0x40, 0x41} {
+			w = append(w, a.devs[addr].regs[reg] == val)
+		}
+	}
+	return w
+}
+
+func TestGroup(t *testing.T) {
+	vs := []struct {
+		name     string
+		parallel int
+		failFast bool
+		// want lists the members written. When sure is false, the
+		// members of other buses may or may not be written.
+		want []bool
+		sure bool
+	}{
+		{"sequential", 0, false, []bool{true, true, false, true, true, true}, true},
+		{"parallel", 3, false, []bool{true, true, false, true, true, true}, true},
+		{"sequential fail fast", 1, true, []bool{true, true, false, false, false, false}, true},
+		{"parallel fail fast", 3, true, []bool{true, true, false, false, true, true}, false},
+	}
+	for _, v := range vs {
+		g, as := groupBuses(t)
+		g.Parallel, g.FailFast = v.parallel, v.failFast
+		err := g.WriteReg(0x10, 0x5a)
+		if !errors.Is(err, syscall.ENXIO) || !strings.Contains(err.Error(), "/dev/i2c-2@40h") {
+			t.Errorf("%s: got %v, want ENXIO from /dev/i2c-2@40h", v.name, err)
+		}
+		if u, ok := err.(interface{ Unwrap() []error }); !ok || len(u.Unwrap()) != 1 {
+			t.Errorf("%s: got %v, want one member's error", v.name, err)
+		}
+		got := written(as, 0x10, 0x5a)
+		for i := range got {
+			if (v.sure || i/2 == 1) && got[i] != v.want[i] {
+				t.Errorf("%s: got members written %v, want %v", v.name, got, v.want)
+				break
+			}
+		}
+	}
+
+	g, as := groupBuses(t)
+	as[1].devs[0x40].nak = false
+	g.Parallel = 2
+	if err := g.UpdateReg(0x10, 0x0f, 0x05); err != nil {
+		t.Errorf("UpdateReg failed: %v", err)
+	}
+	for i, w := range written(as, 0x10, 0x05) {
+		if !w {
+			t.Errorf("member %d not updated", i)
+		}
+	}
+}
+
+func TestWriteAll(t *testing.T) {
+	a := newFakeAdapter(0x40, 0x41, 0x42, 0x44)
+	a.devs[0x41].nak = true
+	a.devs[0x44].short = 1
+	bus := useFake(t, a, 1)
+	opens := 0
+	wrap := wrapFile
+	wrapFile = func(f *os.File) busFile {
+		opens++
+		return wrap(f)
+	}
+
+	addrs := []uint{0x40, 0x41, 0x42, 0x43, 0x44}
+	res, err := WriteAll(bus, addrs, []byte{0x01, 0x80})
+	if err != nil {
+		t.Fatalf("WriteAll failed: %v", err)
+	}
+	if opens != 1 {
+		t.Errorf("bus opened %d times, want 1", opens)
+	}
+	if len(res) != len(addrs) {
+		t.Errorf("got %d results, want %d: %v", len(res), len(addrs), res)
+	}
+	for _, addr := range addrs {
+		err, ok := res[addr]
+		switch addr {
+		case 0x41, 0x43:
+			if !errors.Is(err, syscall.ENXIO) {
+				t.Errorf("address %#x: got %v, want %v", addr, err, syscall.ENXIO)
+			}
+		case 0x44:
+			if !errors.Is(err, ErrTruncated) {
+				t.Errorf("address %#x: got %v, want %v", addr, err, ErrTruncated)
+			}
+		default:
+			if !ok || err != nil {
+				t.Errorf("address %#x: got %v (present=%v), want nil", addr, err, ok)
+			}
+			if got := a.devs[addr].regs[0x01]; got != 0x80 {
+				t.Errorf("address %#x: register 01h = %#02x, want 0x80", addr, got)
+			}
+		}
+	}
+	want := []string{
+		"w 40: 01 80",
+		"w 41: 01 80",
+		"w 42: 01 80",
+		"w 43: 01 80",
+		"w 44: 01 80",
+	}
+	if got := a.ops(); !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := WriteAll(bus+"-missing", addrs, []byte{0x01}); err == nil {
+		t.Error("WriteAll of a missing bus succeeded")
+	}
+}
@@ -8,9 +8,12 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"sync"
 	"syscall"
+	"time"
+	"unsafe"
 )
 
 // RETRIES etc are from /usr/include/linux/i2c-dev.h
@@ -26,22 +29,77 @@ const (
 	SMBUS       = 0x0720
 )
 
+// maxMsg is the largest single read or write supported by the
+// kernel's i2c-dev driver.
+const maxMsg = 8192
+
 // Conn holds an open connection to an i2c device.
 type Conn struct {
 	bus    string
 	addr   uint
+	tenBit bool
 	mu     sync.Mutex
-	f      *os.File
+	f      busFile
 	endian binary.ByteOrder
+
+	// wbuf is a reusable buffer for gathering vectorized writes.
+	wbuf []byte
+	// ptr holds register pointer bytes written ahead of reads.
+	ptr [2]byte
+	// limit, if set, paces transactions.
+	limit *limiter
+	// quirks accommodate non-compliant devices.
+	quirks Quirks
+	// retries is the value last set with SetRetries, if retriesSet.
+	retries    int
+	retriesSet bool
+	// preamble, if set, precedes each register access.
+	preamble []byte
+	// pec records whether SMBus packet error checking is enabled.
+	pec bool
+	// funcs caches the adapter functionality, once funcsKnown.
+	funcs      uint64
+	funcsKnown bool
 }
 
 // ErrInvalid etc are errors reported by the package.
 var (
-	ErrInvalid   = errors.New("invalid connection")
-	ErrClosed    = errors.New("connection closed")
-	ErrTruncated = errors.New("truncated transaction")
+	ErrInvalid     = errors.New("invalid connection")
+	ErrClosed      = errors.New("connection closed")
+	ErrTruncated   = errors.New("truncated transaction")
+	ErrTimeout     = errors.New("timeout")
+	ErrNoEndian    = errors.New("no byte order configured")
+	ErrRange       = errors.New("value out of range")
+	ErrVerify      = errors.New("verification failed")
+	ErrUnsupported = errors.New("unsupported by adapter")
 )
 
+// TruncationError reports a transfer that moved fewer bytes than were
+// expected. It matches ErrTruncated with errors.Is, as well as Err,
+// the error, if any, that ended the transfer.
+type TruncationError struct {
+	Op      string
+	N, Want int
+	Err     error
+}
+
+// Error describes the truncated transfer.
+func (e *TruncationError) Error() string {
+	msg := fmt.Sprintf("%s %d of %d bytes: %v", e.Op, e.N, e.Want, ErrTruncated)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns ErrTruncated and, when set, e.Err.
+func (e *TruncationError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrTruncated}
+	}
+	return []error{ErrTruncated, e.Err}
+}
+
 // ioctl performs an ioctl on the open connection.
 func (c *Conn) ioctl(cmd uintptr, arg uintptr) error {
 	if c == nil {
@@ -50,17 +108,71 @@ func (c *Conn) ioctl(cmd uintptr, arg uintptr) error {
 	if c.f == nil {
 		return ErrClosed
 	}
-	sc, err := c.f.SyscallConn()
+	_, err := c.f.ioctl(cmd, arg)
+	return err
+}
+
+// ioctlPtr performs an ioctl, whose argument is a pointer to a
+// structure, on the open connection.
+func (c *Conn) ioctlPtr(cmd uintptr, arg unsafe.Pointer) error {
+	_, err := c.ioctlPtrRet(cmd, arg)
+	return err
+}
+
+// ioctlPtrRet performs an ioctl as for ioctlPtr, and returns the
+// non-negative value the ioctl returns on success.
+func (c *Conn) ioctlPtrRet(cmd uintptr, arg unsafe.Pointer) (int, error) {
+	if c == nil {
+		return 0, ErrInvalid
+	}
+	if c.f == nil {
+		return 0, ErrClosed
+	}
+	return c.f.ioctlPtr(cmd, arg)
+}
+
+// setAddr selects the device address used by subsequent
+// transactions. The caller must hold c.mu or otherwise have exclusive
+// use of c.
+func (c *Conn) setAddr(addr uint, tenBit bool) error {
+	return c.selectAddr(addr, tenBit, false)
+}
+
+// selectAddr performs setAddr, using SLAVE_FORCE rather than SLAVE
+// when force is true.
+func (c *Conn) selectAddr(addr uint, tenBit, force bool) error {
+	var err error
+	if tenBit {
+		err = c.ioctl(TENBIT, 1)
+	} else {
+		err = c.ioctl(TENBIT, 0)
+	}
+	cmd := uintptr(SLAVE)
+	if force {
+		cmd = SLAVE_FORCE
+	}
+	if err == nil {
+		err = c.ioctl(cmd, uintptr(addr))
+	}
 	if err != nil {
 		return err
 	}
-	sc.Control(func(fd uintptr) {
-		_, _, eno := syscall.Syscall(syscall.SYS_IOCTL, fd, cmd, arg)
-		if eno != 0 {
-			err = eno
-		}
-	})
-	return err
+	c.addr, c.tenBit = addr, tenBit
+	return nil
+}
+
+// lock acquires the mutex of an open connection. On success, the
+// caller is responsible for releasing c.mu.
+func (c *Conn) lock() error {
+	if c == nil {
+		return ErrInvalid
+	}
+	c.mu.Lock()
+	if c.f == nil {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	return nil
 }
 
 // Close shuts down the open connection.
@@ -78,138 +190,695 @@ func (c *Conn) Close() error {
 	return err
 }
 
+// CloseReset writes resetVal to the resetReg register of the device,
+// for example to park it in a low power state, and then closes the
+// connection. The connection is closed even if the reset write fails,
+// but such a failure is reported in the returned error.
+func (c *Conn) CloseReset(resetReg, resetVal byte) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	var resetErr error
+	if err := c.writeRegs(resetReg, []byte{resetVal}); err != nil {
+		resetErr = fmt.Errorf("reset write failed: %w", err)
+	}
+	err := c.f.Close()
+	c.f = nil
+	return errors.Join(resetErr, err)
+}
+
+// SetRetries sets the number of times the kernel's adapter driver
+// retries a transaction, for example when arbitration is lost or the
+// device does not acknowledge it. This concerns only the retries
+// performed by the kernel, and is unrelated to SMBus PEC checking.
+// The setting applies to the bus device file of the connection.
+func (c *Conn) SetRetries(n int) error {
+	if n < 0 {
+		return fmt.Errorf("%d retries: %w", n, ErrRange)
+	}
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	if err := c.ioctl(RETRIES, uintptr(n)); err != nil {
+		return err
+	}
+	c.retries, c.retriesSet = n, true
+	return nil
+}
+
+// Retries returns the retry count last set with SetRetries. The bool
+// return value is false if SetRetries has not succeeded on this
+// connection, in which case the adapter's default applies.
+func (c *Conn) Retries() (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.retries, c.retriesSet
+}
+
+// timeoutUnit is the granularity of the TIMEOUT ioctl.
+const timeoutUnit = 10 * time.Millisecond
+
+// timeoutUnits converts d to the units of the TIMEOUT ioctl, rounding
+// up, with a minimum of one unit.
+func timeoutUnits(d time.Duration) uintptr {
+	n := (d + timeoutUnit - 1) / timeoutUnit
+	if n < 1 {
+		n = 1
+	}
+	return uintptr(n)
+}
+
+// SetTimeout sets how long the kernel's adapter driver waits for a
+// transaction, for example one prolonged by a device stretching the
+// clock, before abandoning it. The kernel counts in units of 10ms, so
+// d is rounded up to a multiple of 10ms: a 5ms timeout becomes 10ms
+// rather than none. The setting applies to the bus device file of the
+// connection.
+func (c *Conn) SetTimeout(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("timeout %v: %w", d, ErrRange)
+	}
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	return c.ioctl(TIMEOUT, timeoutUnits(d))
+}
+
+// TimedTransaction runs fn on the connection and returns the
+// wall-clock time it took to complete, along with any error fn
+// returns. This is useful for characterizing device and bus latency,
+// for example when aligning a clock device to the system clock.
+func (c *Conn) TimedTransaction(fn func(*Conn) error) (time.Duration, error) {
+	if c == nil {
+		return 0, ErrInvalid
+	}
+	start := now()
+	err := fn(c)
+	return now().Sub(start), err
+}
+
 // BusFile is a convenience function for locating the numbered bus
 // device file.
 func BusFile(n uint) string {
 	return fmt.Sprintf("/dev/i2c-%d", n)
 }
 
+// openBus opens a bus device file for use with a series of device
+// addresses, selected with setAddr. The returned connection has no
+// byte order.
+func openBus(bus string) (*Conn, error) {
+	f, err := os.OpenFile(bus, os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{bus: bus, f: wrapFile(f)}, nil
+}
+
 // NewConn establishes a new connection to an addressed device.
 // Whether or not the device uses 10-bit addressing and which
-// endianness it is are device specific considerations.
+// endianness it is are device specific considerations. A nil endian
+// value selects binary.BigEndian. If a kernel driver has claimed the
+// address, the returned error wraps syscall.EBUSY.
 func NewConn(bus string, addr uint, tenBit bool, endian binary.ByteOrder) (*Conn, error) {
+	return newConn(bus, addr, tenBit, false, endian)
+}
+
+// NewConnForce establishes a new connection to an addressed device,
+// as for NewConn, even if a kernel driver has claimed the address.
+// This is intended for debugging. DANGER: the kernel driver is not
+// told of the transactions performed, which may corrupt its view of
+// the device's state, or the device's state itself, for example by
+// moving a register pointer between two of the driver's accesses.
+func NewConnForce(bus string, addr uint, tenBit bool, endian binary.ByteOrder) (*Conn, error) {
+	return newConn(bus, addr, tenBit, true, endian)
+}
+
+// newConn implements NewConn and NewConnForce.
+func newConn(bus string, addr uint, tenBit, force bool, endian binary.ByteOrder) (*Conn, error) {
+	if endian == nil {
+		endian = binary.BigEndian
+	}
 	f, err := os.OpenFile(bus, os.O_RDWR, 0600)
 	if err != nil {
 		return nil, err
 	}
-	c := &Conn{bus: bus, addr: addr, f: f}
-	if tenBit {
-		err = c.ioctl(TENBIT, 1)
-	} else {
-		err = c.ioctl(TENBIT, 0)
-	}
-	if err == nil {
-		err = c.ioctl(SLAVE, uintptr(addr))
-	}
-	if err != nil {
+	c := &Conn{bus: bus, addr: addr, f: wrapFile(f), endian: endian}
+	if err := c.selectAddr(addr, tenBit, force); err != nil {
 		c.Close()
+		if !force && errors.Is(err, syscall.EBUSY) {
+			err = fmt.Errorf("address %#x is claimed by a kernel driver, see NewConnForce: %w", addr, err)
+		}
 		return nil, err
 	}
 	return c, nil
 }
 
+// SetAddr retargets the connection to the device at addr, without
+// reopening the bus device file. This lets one connection serve
+// several devices on a bus in turn. Whether the address is a 10-bit
+// one is given by tenBit.
+func (c *Conn) SetAddr(addr uint, tenBit bool) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	return c.setAddr(addr, tenBit)
+}
+
+// read performs a single read transaction. The caller must hold c.mu.
+func (c *Conn) read(data []byte) (int, error) {
+	c.pace()
+	return c.f.Read(data)
+}
+
+// write performs a single write transaction. The caller must hold
+// c.mu.
+func (c *Conn) write(data []byte) (int, error) {
+	c.pace()
+	return c.f.Write(data)
+}
+
+// readReg writes the reg pointer value and then reads len(buf) bytes
+// into buf. The caller must hold c.mu.
+func (c *Conn) readReg(reg byte, buf []byte) (int, error) {
+	c.ptr[0] = reg
+	return c.readPtr(c.ptr[:1], buf)
+}
+
+// readPtr writes the register pointer bytes ptr and then reads
+// len(buf) bytes into buf, as separate transactions. With a preamble
+// configured, the preamble, pointer and read instead form one
+// combined transaction. The caller must hold c.mu.
+func (c *Conn) readPtr(ptr, buf []byte) (int, error) {
+	if c.preamble != nil {
+		return c.preambled(ptr, buf)
+	}
+	if n, err := c.write(ptr); err != nil {
+		return 0, err
+	} else if n != len(ptr) {
+		return 0, &TruncationError{Op: "write", N: n, Want: len(ptr)}
+	}
+	n, err := c.read(buf)
+	if err == nil && n != len(buf) {
+		err = ErrTruncated
+	}
+	return n, err
+}
+
 // Read reads up to data bytes from the open connection.
 func (c *Conn) Read(data []byte) (int, error) {
-	if c == nil {
-		return 0, ErrInvalid
+	if err := c.lock(); err != nil {
+		return 0, err
 	}
-	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.f == nil {
-		return 0, ErrClosed
+	return c.read(data)
+}
+
+// ReadFull reads exactly len(data) bytes from the open connection. A
+// short read is followed by further reads, each a separate bus
+// transaction, for the remaining bytes. The connection is held for
+// the duration, so no other use of it can intervene. If the device
+// stops supplying data, a *TruncationError reports how many bytes
+// were read.
+func (c *Conn) ReadFull(data []byte) error {
+	if err := c.lock(); err != nil {
+		return err
 	}
-	return c.f.Read(data)
+	defer c.mu.Unlock()
+	done := 0
+	for done < len(data) {
+		n, err := c.read(data[done:])
+		done += n
+		if err != nil && done == 0 {
+			return err
+		} else if err != nil {
+			return &TruncationError{Op: "read", N: done, Want: len(data), Err: err}
+		}
+		if n == 0 {
+			return &TruncationError{Op: "read", N: done, Want: len(data)}
+		}
+	}
+	return nil
 }
 
 // Write writes data bytes to the open connection.
 func (c *Conn) Write(data []byte) (int, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	return c.write(data)
+}
+
+// WriteFull writes all of data to the open connection. A short write
+// is followed by further writes, each a separate bus transaction, of
+// the remaining bytes. The connection is held for the duration. If
+// the device stops accepting data, for example by not acknowledging
+// it part way, a *TruncationError reports how many bytes were
+// written.
+func (c *Conn) WriteFull(data []byte) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	done := 0
+	for done < len(data) {
+		n, err := c.write(data[done:])
+		done += n
+		if err != nil && done == 0 {
+			return err
+		} else if err != nil {
+			return &TruncationError{Op: "write", N: done, Want: len(data), Err: err}
+		}
+		if n == 0 {
+			return &TruncationError{Op: "write", N: done, Want: len(data)}
+		}
+	}
+	return nil
+}
+
+// Writev writes the concatenation of bufs to the open connection as a
+// single i2c write. The i2c-dev driver has no native writev support
+// (it would issue one bus transaction per buffer), so the buffers are
+// gathered into a scratch buffer owned by the connection and reused
+// between calls. This avoids allocating a combined slice for the
+// common register+payload pattern. Only writes of up to wbufMax bytes
+// reuse the buffer, so a single large write does not pin memory, and
+// writes longer than the kernel's limit of 8192 bytes are rejected.
+// The total number of bytes written is returned, and ErrTruncated if
+// that is less than was requested.
+func (c *Conn) Writev(bufs ...[]byte) (int, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	return c.writev(bufs...)
+}
+
+// wbufMax is the largest scratch buffer retained by a connection for
+// Writev.
+const wbufMax = 256
+
+// writev performs Writev. The caller must hold c.mu.
+func (c *Conn) writev(bufs ...[]byte) (int, error) {
+	total := 0
+	for _, b := range bufs {
+		total += len(b)
+	}
+	if total > maxMsg {
+		return 0, fmt.Errorf("%d byte write exceeds limit of %d: %w", total, maxMsg, ErrInvalid)
+	}
+	var d []byte
+	if total > wbufMax {
+		d = make([]byte, 0, total)
+	} else {
+		if c.wbuf == nil {
+			c.wbuf = make([]byte, wbufMax)
+		}
+		d = c.wbuf[:0]
+	}
+	for _, b := range bufs {
+		d = append(d, b...)
+	}
+	n, err := c.write(d)
+	if err == nil && n != total {
+		err = ErrTruncated
+	}
+	return n, err
+}
+
+// byteOrder returns the byte order with which the connection encodes
+// and decodes multi-byte values.
+func (c *Conn) byteOrder() (binary.ByteOrder, error) {
 	if c == nil {
-		return 0, ErrInvalid
+		return nil, ErrInvalid
 	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.f == nil {
-		return 0, ErrClosed
+	if c.endian == nil {
+		return nil, ErrNoEndian
 	}
-	return c.f.Write(data)
+	return c.endian, nil
 }
 
-// ReadUint16 reads a uint16 value from an open connection.
-func (c *Conn) ReadUint16() (uint16, error) {
-	d := make([]byte, 2)
-	if n, err := c.Read(d); err != nil {
-		return 0, err
-	} else if n != len(d) {
-		return 0, ErrTruncated
+// Endian returns the byte order of the connection, or nil if it has
+// none.
+func (c *Conn) Endian() binary.ByteOrder {
+	if c == nil {
+		return nil
 	}
-	return c.endian.Uint16(d), nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.endian
 }
 
-// WriteUint16 writes a uint16 value to an open connection.
-func (c *Conn) WriteUint16(val uint16) error {
+// SetEndian changes the byte order used by subsequent multi-byte
+// reads and writes of the connection. This suits devices whose
+// registers do not all share one byte order. A nil order is rejected
+// with ErrNoEndian.
+func (c *Conn) SetEndian(order binary.ByteOrder) error {
 	if c == nil {
 		return ErrInvalid
 	}
-	d := make([]byte, 2)
-	c.endian.PutUint16(d, val)
-	if n, err := c.Write(d); err != nil {
-		return err
-	} else if n != len(d) {
-		return ErrTruncated
+	if order == nil {
+		return ErrNoEndian
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.endian = order
 	return nil
 }
 
-// ReadUint32 reads a uint32 value from an open connection.
-func (c *Conn) ReadUint32() (uint32, error) {
-	d := make([]byte, 4)
+// ReadUint8 reads a uint8 value from an open connection.
+func (c *Conn) ReadUint8() (uint8, error) {
+	d := make([]byte, 1)
 	if n, err := c.Read(d); err != nil {
 		return 0, err
 	} else if n != len(d) {
 		return 0, ErrTruncated
 	}
-	return c.endian.Uint32(d), nil
+	return d[0], nil
 }
 
-// WriteUint32 writes a uint32 value to an open connection.
-func (c *Conn) WriteUint32(val uint32) error {
-	if c == nil {
-		return ErrInvalid
+// WriteUint8 writes a uint8 value to an open connection.
+func (c *Conn) WriteUint8(val uint8) error {
+	return c.WriteFull([]byte{val})
+}
+
+// readOrdered reads an n byte unsigned value from an open connection
+// and decodes it in the given byte order.
+func (c *Conn) readOrdered(order binary.ByteOrder, n int) (uint64, error) {
+	var d [8]byte
+	if j, err := c.Read(d[:n]); err != nil {
+		return 0, err
+	} else if j != n {
+		return 0, ErrTruncated
+	}
+	return decodeUint(order, d[:n]), nil
+}
+
+// writeOrdered encodes the n byte unsigned value v in the given byte
+// order and writes it to an open connection.
+func (c *Conn) writeOrdered(order binary.ByteOrder, n int, v uint64) error {
+	d := make([]byte, n)
+	encodeUint(order, d, v)
+	return c.WriteFull(d)
+}
+
+// ReadUint16 reads a uint16 value from an open connection.
+func (c *Conn) ReadUint16() (uint16, error) {
+	return ReadInteger[uint16](c)
+}
+
+// ReadUint16LE reads a little endian uint16 value from an open
+// connection, whatever the connection's byte order.
+func (c *Conn) ReadUint16LE() (uint16, error) {
+	v, err := c.readOrdered(binary.LittleEndian, 2)
+	return uint16(v), err
+}
+
+// ReadUint16BE reads a big endian uint16 value from an open
+// connection, whatever the connection's byte order.
+func (c *Conn) ReadUint16BE() (uint16, error) {
+	v, err := c.readOrdered(binary.BigEndian, 2)
+	return uint16(v), err
+}
+
+// WriteUint16 writes a uint16 value to an open connection.
+func (c *Conn) WriteUint16(val uint16) error {
+	return WriteInteger(c, val)
+}
+
+// WriteUint16LE writes a little endian uint16 value to an open
+// connection, whatever the connection's byte order.
+func (c *Conn) WriteUint16LE(val uint16) error {
+	return c.writeOrdered(binary.LittleEndian, 2, uint64(val))
+}
+
+// WriteUint16BE writes a big endian uint16 value to an open
+// connection, whatever the connection's byte order.
+func (c *Conn) WriteUint16BE(val uint16) error {
+	return c.writeOrdered(binary.BigEndian, 2, uint64(val))
+}
+
+// ReadUint24 reads a 3 byte unsigned value from an open connection.
+func (c *Conn) ReadUint24() (uint32, error) {
+	order, err := c.byteOrder()
+	if err != nil {
+		return 0, err
 	}
-	d := make([]byte, 4)
-	c.endian.PutUint32(d, val)
-	if n, err := c.Write(d); err != nil {
+	v, err := c.readOrdered(order, 3)
+	return uint32(v), err
+}
+
+// WriteUint24 writes a 3 byte unsigned value to an open connection.
+// Values greater than 0xffffff are rejected.
+func (c *Conn) WriteUint24(val uint32) error {
+	order, err := c.byteOrder()
+	if err != nil {
 		return err
-	} else if n != len(d) {
-		return ErrTruncated
 	}
-	return nil
+	if val > 0xffffff {
+		return fmt.Errorf("value %#x exceeds 24 bits: %w", val, ErrRange)
+	}
+	return c.writeOrdered(order, 3, uint64(val))
+}
+
+// ReadInt24 reads a 3 byte two's complement value from an open
+// connection, sign extending it from bit 23.
+func (c *Conn) ReadInt24() (int32, error) {
+	v, err := c.ReadUint24()
+	return int32(signExtend(uint64(v), 3)), err
+}
+
+// WriteInt24 writes a 3 byte two's complement value to an open
+// connection. Values outside the range of 24 bits are rejected.
+func (c *Conn) WriteInt24(val int32) error {
+	if val < -0x800000 || val > 0x7fffff {
+		return fmt.Errorf("value %d exceeds 24 bits: %w", val, ErrRange)
+	}
+	return c.WriteUint24(uint32(val) & 0xffffff)
+}
+
+// ReadUint32 reads a uint32 value from an open connection.
+func (c *Conn) ReadUint32() (uint32, error) {
+	return ReadInteger[uint32](c)
+}
+
+// ReadUint32LE reads a little endian uint32 value from an open
+// connection, whatever the connection's byte order.
+func (c *Conn) ReadUint32LE() (uint32, error) {
+	v, err := c.readOrdered(binary.LittleEndian, 4)
+	return uint32(v), err
+}
+
+// ReadUint32BE reads a big endian uint32 value from an open
+// connection, whatever the connection's byte order.
+func (c *Conn) ReadUint32BE() (uint32, error) {
+	v, err := c.readOrdered(binary.BigEndian, 4)
+	return uint32(v), err
+}
+
+// WriteUint32 writes a uint32 value to an open connection.
+func (c *Conn) WriteUint32(val uint32) error {
+	return WriteInteger(c, val)
+}
+
+// WriteUint32LE writes a little endian uint32 value to an open
+// connection, whatever the connection's byte order.
+func (c *Conn) WriteUint32LE(val uint32) error {
+	return c.writeOrdered(binary.LittleEndian, 4, uint64(val))
+}
+
+// WriteUint32BE writes a big endian uint32 value to an open
+// connection, whatever the connection's byte order.
+func (c *Conn) WriteUint32BE(val uint32) error {
+	return c.writeOrdered(binary.BigEndian, 4, uint64(val))
 }
 
 // ReadUint64 reads a uint64 value from an open connection.
 func (c *Conn) ReadUint64() (uint64, error) {
-	d := make([]byte, 8)
-	if n, err := c.Read(d); err != nil {
-		return 0, err
-	} else if n != len(d) {
-		return 0, ErrTruncated
-	}
-	return c.endian.Uint64(d), nil
+	return ReadInteger[uint64](c)
+}
+
+// ReadUint64LE reads a little endian uint64 value from an open
+// connection, whatever the connection's byte order.
+func (c *Conn) ReadUint64LE() (uint64, error) {
+	v, err := c.readOrdered(binary.LittleEndian, 8)
+	return uint64(v), err
+}
+
+// ReadUint64BE reads a big endian uint64 value from an open
+// connection, whatever the connection's byte order.
+func (c *Conn) ReadUint64BE() (uint64, error) {
+	v, err := c.readOrdered(binary.BigEndian, 8)
+	return uint64(v), err
 }
 
 // WriteUint64 writes a uint64 value to an open connection.
 func (c *Conn) WriteUint64(val uint64) error {
-	if c == nil {
-		return ErrInvalid
-	}
-	d := make([]byte, 8)
-	c.endian.PutUint64(d, val)
-	if n, err := c.Write(d); err != nil {
-		return err
-	} else if n != len(d) {
-		return ErrTruncated
-	}
-	return nil
+	return WriteInteger(c, val)
+}
+
+// WriteUint64LE writes a little endian uint64 value to an open
+// connection, whatever the connection's byte order.
+func (c *Conn) WriteUint64LE(val uint64) error {
+	return c.writeOrdered(binary.LittleEndian, 8, uint64(val))
+}
+
+// WriteUint64BE writes a big endian uint64 value to an open
+// connection, whatever the connection's byte order.
+func (c *Conn) WriteUint64BE(val uint64) error {
+	return c.writeOrdered(binary.BigEndian, 8, uint64(val))
+}
+
+// ReadInt16 reads a two's complement int16 value from an open
+// connection.
+func (c *Conn) ReadInt16() (int16, error) {
+	v, err := c.ReadUint16()
+	return int16(v), err
+}
+
+// WriteInt16 writes a two's complement int16 value to an open
+// connection.
+func (c *Conn) WriteInt16(val int16) error {
+	return c.WriteUint16(uint16(val))
+}
+
+// ReadInt16LE reads a little endian two's complement int16 value
+// from an open connection, whatever the connection's byte order.
+func (c *Conn) ReadInt16LE() (int16, error) {
+	v, err := c.ReadUint16LE()
+	return int16(v), err
+}
+
+// WriteInt16LE writes a little endian two's complement int16 value
+// to an open connection, whatever the connection's byte order.
+func (c *Conn) WriteInt16LE(val int16) error {
+	return c.WriteUint16LE(uint16(val))
+}
+
+// ReadInt16BE reads a big endian two's complement int16 value
+// from an open connection, whatever the connection's byte order.
+func (c *Conn) ReadInt16BE() (int16, error) {
+	v, err := c.ReadUint16BE()
+	return int16(v), err
+}
+
+// WriteInt16BE writes a big endian two's complement int16 value
+// to an open connection, whatever the connection's byte order.
+func (c *Conn) WriteInt16BE(val int16) error {
+	return c.WriteUint16BE(uint16(val))
+}
+
+// ReadInt32 reads a two's complement int32 value from an open
+// connection.
+func (c *Conn) ReadInt32() (int32, error) {
+	v, err := c.ReadUint32()
+	return int32(v), err
+}
+
+// WriteInt32 writes a two's complement int32 value to an open
+// connection.
+func (c *Conn) WriteInt32(val int32) error {
+	return c.WriteUint32(uint32(val))
+}
+
+// ReadInt32LE reads a little endian two's complement int32 value
+// from an open connection, whatever the connection's byte order.
+func (c *Conn) ReadInt32LE() (int32, error) {
+	v, err := c.ReadUint32LE()
+	return int32(v), err
+}
+
+// WriteInt32LE writes a little endian two's complement int32 value
+// to an open connection, whatever the connection's byte order.
+func (c *Conn) WriteInt32LE(val int32) error {
+	return c.WriteUint32LE(uint32(val))
+}
+
+// ReadInt32BE reads a big endian two's complement int32 value
+// from an open connection, whatever the connection's byte order.
+func (c *Conn) ReadInt32BE() (int32, error) {
+	v, err := c.ReadUint32BE()
+	return int32(v), err
+}
+
+// WriteInt32BE writes a big endian two's complement int32 value
+// to an open connection, whatever the connection's byte order.
+func (c *Conn) WriteInt32BE(val int32) error {
+	return c.WriteUint32BE(uint32(val))
+}
+
+// ReadInt64 reads a two's complement int64 value from an open
+// connection.
+func (c *Conn) ReadInt64() (int64, error) {
+	v, err := c.ReadUint64()
+	return int64(v), err
+}
+
+// WriteInt64 writes a two's complement int64 value to an open
+// connection.
+func (c *Conn) WriteInt64(val int64) error {
+	return c.WriteUint64(uint64(val))
+}
+
+// ReadInt64LE reads a little endian two's complement int64 value
+// from an open connection, whatever the connection's byte order.
+func (c *Conn) ReadInt64LE() (int64, error) {
+	v, err := c.ReadUint64LE()
+	return int64(v), err
+}
+
+// WriteInt64LE writes a little endian two's complement int64 value
+// to an open connection, whatever the connection's byte order.
+func (c *Conn) WriteInt64LE(val int64) error {
+	return c.WriteUint64LE(uint64(val))
+}
+
+// ReadInt64BE reads a big endian two's complement int64 value
+// from an open connection, whatever the connection's byte order.
+func (c *Conn) ReadInt64BE() (int64, error) {
+	v, err := c.ReadUint64BE()
+	return int64(v), err
+}
+
+// WriteInt64BE writes a big endian two's complement int64 value
+// to an open connection, whatever the connection's byte order.
+func (c *Conn) WriteInt64BE(val int64) error {
+	return c.WriteUint64BE(uint64(val))
+}
+
+// ReadFloat32 reads an IEEE-754 float32 value from an open
+// connection.
+func (c *Conn) ReadFloat32() (float32, error) {
+	v, err := c.ReadUint32()
+	return math.Float32frombits(v), err
+}
+
+// WriteFloat32 writes an IEEE-754 float32 value to an open
+// connection.
+func (c *Conn) WriteFloat32(val float32) error {
+	return c.WriteUint32(math.Float32bits(val))
+}
+
+// ReadFloat64 reads an IEEE-754 float64 value from an open
+// connection.
+func (c *Conn) ReadFloat64() (float64, error) {
+	v, err := c.ReadUint64()
+	return math.Float64frombits(v), err
+}
+
+// WriteFloat64 writes an IEEE-754 float64 value to an open
+// connection.
+func (c *Conn) WriteFloat64(val float64) error {
+	return c.WriteUint64(math.Float64bits(val))
 }
 
 // RegN reads n bytes starting from the register value from the open
@@ -219,16 +888,99 @@ func (c *Conn) RegN(reg, n int) ([]byte, error) {
 	if n < 1 || c == nil || reg < 0 || reg > 0xff {
 		return nil, ErrInvalid
 	}
-	if j, err := c.Write([]byte{byte(reg)}); err != nil || j != 1 {
+	d := make([]byte, n)
+	if _, err := c.ReadRegBuf(byte(reg), d); err != nil {
 		return nil, ErrInvalid
 	}
-	d := make([]byte, n)
-	if j, err := c.Read(d); err != nil || j != n {
+	return d, nil
+}
+
+// ReadRegBuf reads from the device starting at register reg into the
+// caller supplied buf, returning the number of bytes read. The length
+// of buf determines the size of the read. Unlike RegN, no memory is
+// allocated, making this suited to high rate polling loops. The
+// register write and the read are performed without releasing the
+// connection, and ErrTruncated is returned for a short read.
+func (c *Conn) ReadRegBuf(reg byte, buf []byte) (int, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	return c.readReg(reg, buf)
+}
+
+// Regs reads len(buf) bytes from the device, starting at register
+// reg, into buf. When the adapter supports plain i2c transfers, the
+// register pointer write and the read form one combined transaction
+// with a repeated start. Otherwise, they are separate transactions,
+// made without releasing the connection. If fewer than len(buf) bytes
+// are read, a *TruncationError reports how many.
+func (c *Conn) Regs(reg byte, buf []byte) (int, error) {
+	if len(buf) > maxMsg {
+		return 0, ErrInvalid
+	}
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	if funcs, err := c.adapterFuncs(); err == nil && funcs&FUNC_I2C != 0 && c.preamble == nil {
+		ptr := [1]byte{reg}
+		return c.writeRead(ptr[:], buf)
+	}
+	n, err := c.readReg(reg, buf)
+	var te *TruncationError
+	if errors.Is(err, ErrTruncated) && !errors.As(err, &te) {
+		err = &TruncationError{Op: "read", N: n, Want: len(buf)}
+	}
+	return n, err
+}
+
+// ReadReg reads len(buf) bytes from the device, starting at register
+// reg, into buf. The register pointer write and the read form one
+// combined transaction with a repeated start, as for WriteRead. Use
+// ReadRegBuf for devices, or adapters, that need them separated.
+func (c *Conn) ReadReg(reg byte, buf []byte) (int, error) {
+	ptr := [1]byte{reg}
+	return c.WriteRead(ptr[:], buf)
+}
+
+// ReadRegBytes reads n bytes from the device, starting at register
+// reg, in one combined transaction. The register pointer write and
+// the read are separated by a repeated start, so no other transaction
+// can move the pointer between them.
+func (c *Conn) ReadRegBytes(reg byte, n int) ([]byte, error) {
+	if n < 1 || n > maxMsg {
 		return nil, ErrInvalid
 	}
+	d := make([]byte, n)
+	if _, err := c.ReadReg(reg, d); err != nil {
+		return nil, err
+	}
 	return d, nil
 }
 
+// WriteReg writes data to the device, starting at register reg, in a
+// single write transaction. The number of data bytes written,
+// excluding the register pointer, is returned, along with
+// ErrTruncated if that is less than len(data).
+func (c *Conn) WriteReg(reg byte, data []byte) (int, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	if c.preamble != nil {
+		if _, err := c.preambled(append([]byte{reg}, data...), nil); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	n, err := c.writev([]byte{reg}, data)
+	if n > 0 {
+		n--
+	}
+	return n, err
+}
+
 // Reg reads a single byte sized register value from the open
 // connection. This sequence is equivalent to a write of the register
 // value followed by a single byte read.
@@ -239,3 +991,62 @@ func (c *Conn) Reg(reg int) (byte, error) {
 	}
 	return d[0], nil
 }
+
+// Reg16 reads the byte value of register reg of a device with 16-bit
+// register addresses. The address is sent high byte first, whatever
+// the connection's byte order, and the value is read with a repeated
+// start, using WriteRead.
+func (c *Conn) Reg16(reg uint16) (byte, error) {
+	ptr := [2]byte{byte(reg >> 8), byte(reg)}
+	var d [1]byte
+	if _, err := c.WriteRead(ptr[:], d[:]); err != nil {
+		return 0, err
+	}
+	return d[0], nil
+}
+
+// littleEndian indicates whether order is little endian.
+func littleEndian(order binary.ByteOrder) bool {
+	var d [2]byte
+	order.PutUint16(d[:], 1)
+	return d[0] == 1
+}
+
+// decodeUint decodes an unsigned integer of up to 8 bytes from d in
+// the indicated byte order.
+func decodeUint(order binary.ByteOrder, d []byte) uint64 {
+	var v uint64
+	if littleEndian(order) {
+		for i := len(d) - 1; i >= 0; i-- {
+			v = v<<8 | uint64(d[i])
+		}
+	} else {
+		for _, b := range d {
+			v = v<<8 | uint64(b)
+		}
+	}
+	return v
+}
+
+// encodeUint encodes the low len(d) bytes of v into d in the
+// indicated byte order.
+func encodeUint(order binary.ByteOrder, d []byte, v uint64) {
+	if littleEndian(order) {
+		for i := range d {
+			d[i] = byte(v)
+			v >>= 8
+		}
+	} else {
+		for i := len(d) - 1; i >= 0; i-- {
+			d[i] = byte(v)
+			v >>= 8
+		}
+	}
+}
+
+// signExtend interprets the low n bytes of v as a two's complement
+// value.
+func signExtend(v uint64, n int) int64 {
+	shift := 64 - 8*uint(n)
+	return int64(v<<shift) >> shift
+}
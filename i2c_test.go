@@ -0,0 +1,504 @@
+package i2c
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewConnNilEndian(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	a.files = true
+	name := useFake(t, a, 1)
+	if err := os.WriteFile(name, []byte{0x12, 0x34}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewConn(name, 0x40, false, nil)
+	if err != nil {
+		t.Fatalf("NewConn failed: %v", err)
+	}
+	if got := c.Endian(); got != binary.BigEndian {
+		t.Errorf("got endian %v, want big endian", got)
+	}
+	if v, err := c.ReadUint16(); err != nil || v != 0x1234 {
+		t.Errorf("ReadUint16 got %#x, %v, want 0x1234", v, err)
+	}
+	if err := c.WriteUint16(0xabcd); err != nil {
+		t.Errorf("WriteUint16 failed: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if d, _ := os.ReadFile(name); !bytes.Equal(d, []byte{0x12, 0x34, 0xab, 0xcd}) {
+		t.Errorf("file holds % x, want 12 34 ab cd", d)
+	}
+}
+
+func TestNoEndian(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, nil)
+	if _, err := c.ReadUint16(); err != ErrNoEndian {
+		t.Errorf("ReadUint16 got %v, want ErrNoEndian", err)
+	}
+	if err := c.WriteUint32(1); err != ErrNoEndian {
+		t.Errorf("WriteUint32 got %v, want ErrNoEndian", err)
+	}
+	if _, err := c.SMBusReadWordData(0); err != ErrNoEndian {
+		t.Errorf("SMBusReadWordData got %v, want ErrNoEndian", err)
+	}
+	if err := c.SMBusWriteWordData(0, 1); err != ErrNoEndian {
+		t.Errorf("SMBusWriteWordData got %v, want ErrNoEndian", err)
+	}
+	if _, err := c.SMBusProcessCall(0, 1); err != ErrNoEndian {
+		t.Errorf("SMBusProcessCall got %v, want ErrNoEndian", err)
+	}
+	if ops := a.ops(); len(ops) != 0 {
+		t.Errorf("bus accessed without a byte order: %q", ops)
+	}
+
+	var nc *Conn
+	if _, err := nc.ReadUint16(); err != ErrInvalid {
+		t.Errorf("nil ReadUint16 got %v, want ErrInvalid", err)
+	}
+	if err := nc.WriteUint16(1); err != ErrInvalid {
+		t.Errorf("nil WriteUint16 got %v, want ErrInvalid", err)
+	}
+}
+
+func TestSMBusWordOrder(t *testing.T) {
+	a := newFakeAdapter(0x0b)
+	a.devs[0x0b].regs[0x10] = 0x34
+	a.devs[0x0b].regs[0x11] = 0x12
+	vs := []struct {
+		order binary.ByteOrder
+		want  uint16
+	}{
+		{binary.LittleEndian, 0x1234},
+		{binary.BigEndian, 0x3412},
+	}
+	for _, v := range vs {
+		c := newFakeConn(t, a, 0x0b, v.order)
+		got, err := c.SMBusReadWordData(0x10)
+		if err != nil || got != v.want {
+			t.Errorf("%v: got %#x, %v, want %#x", v.order, got, err, v.want)
+		}
+		if err := c.SMBusWriteWordData(0x20, got); err != nil {
+			t.Fatalf("%v: write failed: %v", v.order, err)
+		}
+		if r := a.devs[0x0b].regs[0x20:0x22]; !bytes.Equal(r, []byte{0x34, 0x12}) {
+			t.Errorf("%v: wrote % x, want 34 12", v.order, r)
+		}
+	}
+}
+
+func TestClosed(t *testing.T) {
+	c := newFakeConn(t, newFakeAdapter(0x40), 0x40, binary.BigEndian)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := c.ReadUint16(); !errors.Is(err, ErrClosed) {
+		t.Errorf("ReadUint16 after Close got %v, want ErrClosed", err)
+	}
+	if err := c.Close(); err != ErrClosed {
+		t.Errorf("second Close got %v, want ErrClosed", err)
+	}
+}
+
+func TestReadFullPipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	c := newFileConn(r)
+	defer c.Close()
+	want := []byte{1, 2, 3, 4, 5, 6, 7}
+	go func() {
+		for i := 0; i < len(want); i += 3 {
+			j := i + 3
+			if j > len(want) {
+				j = len(want)
+			}
+			w.Write(want[i:j])
+		}
+	}()
+	got := make([]byte, len(want))
+	if err := c.ReadFull(got); err != nil {
+		t.Fatalf("ReadFull failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+}
+
+func TestReadFullShort(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "short")
+	if err := os.WriteFile(name, []byte{1, 2, 3}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newFileConn(f)
+	defer c.Close()
+	err = c.ReadFull(make([]byte, 5))
+	var te *TruncationError
+	if !errors.As(err, &te) || te.N != 3 || te.Want != 5 || te.Op != "read" {
+		t.Fatalf("got %v, want 3 of 5 byte read truncation", err)
+	}
+	if !errors.Is(err, ErrTruncated) || !errors.Is(err, io.EOF) {
+		t.Errorf("%v does not match both ErrTruncated and io.EOF", err)
+	}
+	if err := c.ReadFull(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read at end of file got %v, want io.EOF", err)
+	}
+}
+
+func TestWriteFull(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	d := a.devs[0x40]
+	d.short = 2
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	if err := c.WriteFull([]byte{1, 2, 3, 4, 5}); err != nil {
+		t.Fatalf("WriteFull failed: %v", err)
+	}
+	want := []string{"w 40: 01 02 03 04 05", "w 40: 03 04 05", "w 40: 05"}
+	if got := a.ops(); !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	d.nakAfter = 4
+	err := c.WriteFull([]byte{1, 2, 3, 4, 5, 6})
+	var te *TruncationError
+	if !errors.As(err, &te) || te.N != 4 || te.Want != 6 || te.Op != "write" {
+		t.Fatalf("got %v, want 4 of 6 byte write truncation", err)
+	}
+	if !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("%v does not wrap ENXIO", err)
+	}
+	if err := c.WriteFull([]byte{1}); err != syscall.ENXIO {
+		t.Errorf("unacknowledged write got %v, want ENXIO", err)
+	}
+}
+
+// equalStrings indicates whether a and b hold the same strings.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTruncationError(t *testing.T) {
+	err := error(&TruncationError{Op: "write", N: 1, Want: 3, Err: syscall.ENXIO})
+	if got, want := err.Error(), "write 1 of 3 bytes: truncated transaction: "+syscall.ENXIO.Error(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !errors.Is(err, ErrTruncated) || !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("%v does not match ErrTruncated and ENXIO", err)
+	}
+	err = &TruncationError{Op: "read", N: 0, Want: 2}
+	if got, want := err.Error(), "read 0 of 2 bytes: truncated transaction"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if errors.Is(err, syscall.ENXIO) {
+		t.Errorf("%v unexpectedly matches ENXIO", err)
+	}
+}
+
+func TestWriteUint(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	be := newFakeConn(t, a, 0x40, binary.BigEndian)
+	le := newFakeConn(t, a, 0x40, binary.LittleEndian)
+	vs := []struct {
+		fn   func() error
+		want string
+	}{
+		{func() error { return be.WriteUint8(0x12) }, "w 40: 12"},
+		{func() error { return be.WriteUint16(0x1234) }, "w 40: 12 34"},
+		{func() error { return le.WriteUint16(0x1234) }, "w 40: 34 12"},
+		{func() error { return be.WriteUint16LE(0x1234) }, "w 40: 34 12"},
+		{func() error { return le.WriteUint16BE(0x1234) }, "w 40: 12 34"},
+		{func() error { return be.WriteUint24(0x123456) }, "w 40: 12 34 56"},
+		{func() error { return le.WriteUint24(0x123456) }, "w 40: 56 34 12"},
+		{func() error { return be.WriteUint32(0x12345678) }, "w 40: 12 34 56 78"},
+		{func() error { return le.WriteUint32(0x12345678) }, "w 40: 78 56 34 12"},
+		{func() error { return be.WriteUint64(0x0102030405060708) }, "w 40: 01 02 03 04 05 06 07 08"},
+		{func() error { return le.WriteUint64(0x0102030405060708) }, "w 40: 08 07 06 05 04 03 02 01"},
+	}
+	for i, v := range vs {
+		if err := v.fn(); err != nil {
+			t.Errorf("%d: write failed: %v", i, err)
+			continue
+		}
+		if got := a.ops(); len(got) != 1 || got[0] != v.want {
+			t.Errorf("%d: got %q, want %q", i, got, v.want)
+		}
+	}
+	if err := be.WriteUint24(0x1000000); !errors.Is(err, ErrRange) {
+		t.Errorf("25 bit WriteUint24 got %v, want ErrRange", err)
+	}
+}
+
+func TestTypedTruncation(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	d := a.devs[0x40]
+
+	d.short = -1
+	if _, err := c.ReadUint8(); err != ErrTruncated {
+		t.Errorf("ReadUint8 got %v, want ErrTruncated", err)
+	}
+	err := c.WriteUint8(1)
+	var te *TruncationError
+	if !errors.As(err, &te) || te.N != 0 || te.Want != 1 {
+		t.Errorf("WriteUint8 got %v, want 0 of 1 byte truncation", err)
+	}
+	if err := c.WriteUint32(1); !errors.Is(err, ErrTruncated) {
+		t.Errorf("WriteUint32 got %v, want ErrTruncated", err)
+	}
+
+	d.short = 1
+	if _, err := c.ReadUint16(); err != ErrTruncated {
+		t.Errorf("ReadUint16 got %v, want ErrTruncated", err)
+	}
+	if _, err := c.ReadUint64(); err != ErrTruncated {
+		t.Errorf("ReadUint64 got %v, want ErrTruncated", err)
+	}
+	if _, err := c.ReadFloat32(); err != ErrTruncated {
+		t.Errorf("ReadFloat32 got %v, want ErrTruncated", err)
+	}
+	if err := c.WriteUint16(0x1234); err != nil {
+		t.Errorf("WriteUint16 did not complete a short write: %v", err)
+	}
+}
+
+func TestWritev(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	if n, err := c.Writev([]byte{0x10}, []byte{1, 2}, nil, []byte{3}); err != nil || n != 4 {
+		t.Fatalf("Writev got %d, %v, want 4", n, err)
+	}
+	if got, want := a.ops(), []string{"w 40: 10 01 02 03"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if n, err := c.Writev([]byte{0x10}, make([]byte, 1000)); err != nil || n != 1001 {
+		t.Errorf("large Writev got %d, %v, want 1001", n, err)
+	}
+	if cap(c.wbuf) > wbufMax {
+		t.Errorf("scratch buffer grew to %d bytes", cap(c.wbuf))
+	}
+	a.ops()
+	if _, err := c.Writev([]byte{0x10}, make([]byte, maxMsg)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("oversized Writev got %v, want ErrInvalid", err)
+	}
+	if ops := a.ops(); len(ops) != 0 {
+		t.Errorf("oversized Writev reached the bus: %q", ops)
+	}
+	a.devs[0x40].short = 2
+	if n, err := c.Writev([]byte{0x10}, []byte{1, 2}); err != ErrTruncated || n != 2 {
+		t.Errorf("short Writev got %d, %v, want 2, ErrTruncated", n, err)
+	}
+}
+
+// openDev returns a connection whose reads and writes use the named
+// device file, such as /dev/null.
+func openDev(b *testing.B, name string) *Conn {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		b.Skip(err)
+	}
+	c := newFileConn(f)
+	b.Cleanup(func() { c.Close() })
+	return c
+}
+
+func BenchmarkWritev(b *testing.B) {
+	c := openDev(b, os.DevNull)
+	reg, data := []byte{0x10}, make([]byte, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Writev(reg, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteConcat(b *testing.B) {
+	c := openDev(b, os.DevNull)
+	reg, data := []byte{0x10}, make([]byte, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Write(append(append([]byte(nil), reg...), data...)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadRegBuf(b *testing.B) {
+	c := openDev(b, "/dev/zero")
+	buf := make([]byte, 6)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.ReadRegBuf(0x10, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRegN(b *testing.B) {
+	c := openDev(b, "/dev/zero")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.RegN(0x10, 6); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReadRegBufAllocs(t *testing.T) {
+	c := newFakeConn(t, newFakeAdapter(0x40), 0x40, binary.BigEndian)
+	c.f.(*fakeFile).a.devs[0x40].regs[0x10] = 0x5a
+	buf := make([]byte, 1)
+	if _, err := c.ReadRegBuf(0x10, buf); err != nil || buf[0] != 0x5a {
+		t.Fatalf("ReadRegBuf got %#x, %v, want 0x5a", buf[0], err)
+	}
+	f, err := os.OpenFile("/dev/zero", os.O_RDWR, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	c = newFileConn(f)
+	defer c.Close()
+	if n := testing.AllocsPerRun(100, func() { c.ReadRegBuf(0x10, buf) }); n != 0 {
+		t.Errorf("ReadRegBuf made %v allocations, want 0", n)
+	}
+}
+
+// closeLogged records in the adapter's log when the file is closed.
+type closeLogged struct {
+	*fakeFile
+}
+
+func (f closeLogged) Close() error {
+	f.a.mu.Lock()
+	f.a.log = append(f.a.log, "close")
+	f.a.mu.Unlock()
+	return f.fakeFile.Close()
+}
+
+func TestCloseReset(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	ff := c.f.(*fakeFile)
+	c.f = closeLogged{ff}
+	if err := c.CloseReset(0x01, 0x80); err != nil {
+		t.Errorf("CloseReset failed: %v", err)
+	}
+	if got, want := a.ops(), []string{"w 40: 01 80", "close"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !ff.closed {
+		t.Error("file left open")
+	}
+	if err := c.CloseReset(0x01, 0x80); !errors.Is(err, ErrClosed) {
+		t.Errorf("closed connection: got %v, want %v", err, ErrClosed)
+	}
+
+	c = newFakeConn(t, a, 0x40, binary.BigEndian)
+	ff = c.f.(*fakeFile)
+	c.f = closeLogged{ff}
+	a.devs[0x40].nak = true
+	err := c.CloseReset(0x01, 0x80)
+	if !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("failed reset: got %v, want %v", err, syscall.ENXIO)
+	}
+	if got, want := a.ops(), []string{"w 40: 01 80", "close"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !ff.closed {
+		t.Error("file left open after a failed reset")
+	}
+	if err := c.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Close after CloseReset: got %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestSetTimeout(t *testing.T) {
+	vs := []struct {
+		d    time.Duration
+		want uintptr
+	}{
+		{0, 1},
+		{time.Nanosecond, 1},
+		{5 * time.Millisecond, 1},
+		{10 * time.Millisecond, 1},
+		{11 * time.Millisecond, 2},
+		{20 * time.Millisecond, 2},
+		{time.Second, 100},
+	}
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	for _, v := range vs {
+		if got := timeoutUnits(v.d); got != v.want {
+			t.Errorf("timeoutUnits(%v) = %d, want %d", v.d, got, v.want)
+		}
+		if err := c.SetTimeout(v.d); err != nil {
+			t.Errorf("SetTimeout(%v) failed: %v", v.d, err)
+		} else if got := a.args[TIMEOUT]; got != v.want {
+			t.Errorf("SetTimeout(%v) passed %d, want %d", v.d, got, v.want)
+		}
+	}
+	a.args[TIMEOUT] = 0
+	if err := c.SetTimeout(-time.Millisecond); !errors.Is(err, ErrRange) {
+		t.Errorf("negative timeout: got %v, want %v", err, ErrRange)
+	}
+	if a.args[TIMEOUT] != 0 {
+		t.Error("negative timeout reached the adapter")
+	}
+}
+
+func TestRegsTruncation(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	a.funcs &^= FUNC_I2C
+	d := a.devs[0x40]
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	vs := []struct {
+		short int
+		op    string
+		n     int
+		want  int
+	}{
+		{-1, "write", 0, 1},
+		{2, "read", 2, 4},
+	}
+	for _, v := range vs {
+		d.short = v.short
+		n, err := c.Regs(0x10, make([]byte, 4))
+		var te *TruncationError
+		if !errors.As(err, &te) {
+			t.Errorf("short %s: got %v, want a *TruncationError", v.op, err)
+			continue
+		}
+		if te.Op != v.op || te.N != v.n || te.Want != v.want {
+			t.Errorf("short %s: got %+v, want %s of %d of %d bytes", v.op, te, v.op, v.n, v.want)
+		}
+		if !errors.Is(err, ErrTruncated) {
+			t.Errorf("short %s: %v does not match ErrTruncated", v.op, err)
+		}
+		if n != v.n {
+			t.Errorf("short %s: got %d bytes, want %d", v.op, n, v.n)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package i2c
+
+// Indirect accesses a device with a large, sparse register space
+// reached through a pair of registers: a write to the index register
+// selects a sub-register, which is then read or written through the
+// data register.
+type Indirect struct {
+	c           *Conn
+	index, data byte
+}
+
+// NewIndirect returns an Indirect for the device connected to c,
+// using the index and data registers indicated.
+func NewIndirect(c *Conn, index, data byte) *Indirect {
+	return &Indirect{c: c, index: index, data: data}
+}
+
+// Read reads sub-register sub. The index write and the data read are
+// performed without releasing the connection.
+func (i *Indirect) Read(sub byte) (byte, error) {
+	if err := i.c.lock(); err != nil {
+		return 0, err
+	}
+	defer i.c.mu.Unlock()
+	if err := i.c.writeRegs(i.index, []byte{sub}); err != nil {
+		return 0, err
+	}
+	var d [1]byte
+	if _, err := i.c.readReg(i.data, d[:]); err != nil {
+		return 0, err
+	}
+	return d[0], nil
+}
+
+// Write writes val to sub-register sub. The index write and the data
+// write are performed without releasing the connection.
+func (i *Indirect) Write(sub, val byte) error {
+	if err := i.c.lock(); err != nil {
+		return err
+	}
+	defer i.c.mu.Unlock()
+	if err := i.c.writeRegs(i.index, []byte{sub}); err != nil {
+		return err
+	}
+	return i.c.writeRegs(i.data, []byte{val})
+}
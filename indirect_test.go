@@ -0,0 +1,41 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestIndirect(t *testing.T) {
+	a := newFakeAdapter(0x48)
+	d := a.devs[0x48]
+	c := newFakeConn(t, a, 0x48, binary.BigEndian)
+	ind := NewIndirect(c, 0x00, 0x01)
+
+	if err := ind.Write(0x3c, 0x5a); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got, want := a.ops(), []string{"w 48: 00 3c", "w 48: 01 5a"}; !equalStrings(got, want) {
+		t.Errorf("Write: got %q, want %q", got, want)
+	}
+	d.regs[0x01] = 0xa5
+	v, err := ind.Read(0x3d)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if v != 0xa5 {
+		t.Errorf("Read got %#02x, want 0xa5", v)
+	}
+	if got, want := a.ops(), []string{"w 48: 00 3d", "w 48: 01", "r 48: 1"}; !equalStrings(got, want) {
+		t.Errorf("Read: got %q, want %q", got, want)
+	}
+
+	d.nak = true
+	if _, err := ind.Read(0x3e); !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("failed index write: got %v, want %v", err, syscall.ENXIO)
+	}
+	if got, want := a.ops(), []string{"w 48: 00 3e"}; !equalStrings(got, want) {
+		t.Errorf("failed index write: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+)
+
+// fcntl performs an fcntl operation on the connection's file.
+func (c *Conn) fcntl(cmd, arg uintptr) (uintptr, error) {
+	r, err := c.f.fcntl(cmd, arg)
+	return uintptr(r), err
+}
+
+// Inheritable indicates whether the connection's file descriptor will
+// be inherited by programs exec'd by this process.
+func (c *Conn) Inheritable() (bool, error) {
+	if err := c.lock(); err != nil {
+		return false, err
+	}
+	defer c.mu.Unlock()
+	flags, err := c.fcntl(syscall.F_GETFD, 0)
+	if err != nil {
+		return false, err
+	}
+	return flags&syscall.FD_CLOEXEC == 0, nil
+}
+
+// SetInheritable controls whether the connection's file descriptor is
+// inherited by programs exec'd by this process. This clears or sets
+// the descriptor's close-on-exec flag.
+func (c *Conn) SetInheritable(on bool) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	flags, err := c.fcntl(syscall.F_GETFD, 0)
+	if err != nil {
+		return err
+	}
+	if on {
+		flags &^= syscall.FD_CLOEXEC
+	} else {
+		flags |= syscall.FD_CLOEXEC
+	}
+	_, err = c.fcntl(syscall.F_SETFD, flags)
+	return err
+}
+
+// Fd returns the file descriptor number of the open connection.
+func (c *Conn) Fd() (uintptr, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	return c.f.fd()
+}
+
+// NewConnFD establishes a connection to an addressed device over an
+// already open bus file descriptor, fd, for example one inherited from
+// a parent process. The bus argument names the device file fd refers
+// to. The returned connection takes ownership of fd. As for NewConn,
+// a nil endian value selects binary.BigEndian.
+//
+// A privileged parent process can open a bus device and hand the
+// connection to a less privileged helper program it execs. Files are
+// opened close-on-exec by default, so the parent must first call
+// SetInheritable(true) and tell the child the descriptor number, from
+// Fd, via its arguments or environment. The child then passes it to
+// NewConnFD. When starting the child with os/exec, ExtraFiles already
+// arranges for inheritance, but the descriptor number seen by the
+// child is 3 plus the index in ExtraFiles.
+func NewConnFD(fd uintptr, bus string, addr uint, tenBit bool, endian binary.ByteOrder) (*Conn, error) {
+	if endian == nil {
+		endian = binary.BigEndian
+	}
+	f := os.NewFile(fd, bus)
+	if f == nil {
+		return nil, ErrInvalid
+	}
+	c := &Conn{bus: bus, f: wrapFile(f), endian: endian}
+	if err := c.setAddr(addr, tenBit); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,104 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// inheritEnv names the environment variable that passes the inherited
+// descriptor number to TestInheritChild.
+const inheritEnv = "I2C_TEST_INHERIT_FD"
+
+// TestInheritChild is run by TestInherit in an exec'd copy of the test
+// binary. It rebuilds a connection from the inherited descriptor and
+// prints what it reads.
+func TestInheritChild(t *testing.T) {
+	s := os.Getenv(inheritEnv)
+	if s == "" {
+		t.Skip("only run by TestInherit")
+	}
+	fd, err := strconv.ParseUint(s, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The inherited file is not a bus device file, so simulate the
+	// ioctls but use its reads.
+	wrapFile = func(f *os.File) busFile {
+		return &fakeFile{a: newFakeAdapter(), file: f}
+	}
+	c, err := NewConnFD(uintptr(fd), "inherited", 0x40, false, binary.BigEndian)
+	if err != nil {
+		t.Fatalf("NewConnFD failed: %v", err)
+	}
+	defer c.Close()
+	buf := make([]byte, 5)
+	if err := c.ReadFull(buf); err != nil {
+		fmt.Printf("read failed: %v\n", err)
+		return
+	}
+	fmt.Printf("read %q\n", buf)
+}
+
+func TestInherit(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "bus")
+	if err := os.WriteFile(name, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newFileConn(f)
+	defer c.Close()
+	fd, err := c.Fd()
+	if err != nil {
+		t.Fatalf("Fd failed: %v", err)
+	}
+	cloexec := func() bool {
+		flags, err := c.fcntl(syscall.F_GETFD, 0)
+		if err != nil {
+			t.Fatalf("F_GETFD failed: %v", err)
+		}
+		return flags&syscall.FD_CLOEXEC != 0
+	}
+	child := func() string {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestInheritChild$")
+		cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", inheritEnv, fd))
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Logf("child output:\n%s", out)
+		}
+		return string(out)
+	}
+
+	if on, err := c.Inheritable(); err != nil || on || !cloexec() {
+		t.Fatalf("new connection is inheritable (%v, %v)", on, err)
+	}
+	if out := child(); strings.Contains(out, `read "hello"`) {
+		t.Errorf("child read the descriptor before SetInheritable:\n%s", out)
+	}
+
+	if err := c.SetInheritable(true); err != nil {
+		t.Fatalf("SetInheritable(true) failed: %v", err)
+	}
+	if on, err := c.Inheritable(); err != nil || !on || cloexec() {
+		t.Errorf("Inheritable got %v, %v with FD_CLOEXEC=%v", on, err, cloexec())
+	}
+	if out := child(); !strings.Contains(out, `read "hello"`) {
+		t.Errorf("child did not read the inherited descriptor:\n%s", out)
+	}
+
+	if err := c.SetInheritable(false); err != nil {
+		t.Fatalf("SetInheritable(false) failed: %v", err)
+	}
+	if on, err := c.Inheritable(); err != nil || on || !cloexec() {
+		t.Errorf("Inheritable got %v, %v with FD_CLOEXEC=%v", on, err, cloexec())
+	}
+}
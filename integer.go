@@ -0,0 +1,37 @@
+package i2c
+
+import "unsafe"
+
+// Integer is the set of integer types accepted by ReadInteger and
+// WriteInteger. Only fixed width types are included: the size of int,
+// uint and uintptr varies by platform, and so would the number of
+// bytes transferred.
+type Integer interface {
+	~int8 | ~int16 | ~int32 | ~int64 |
+		~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// ReadInteger reads a value of type T from an open connection,
+// decoding it in the connection's byte order. The size of T
+// determines the number of bytes read. This permits device drivers to
+// be written once for registers of any width.
+func ReadInteger[T Integer](c *Conn) (T, error) {
+	order, err := c.byteOrder()
+	if err != nil {
+		return 0, err
+	}
+	var v T
+	u, err := c.readOrdered(order, int(unsafe.Sizeof(v)))
+	return T(u), err
+}
+
+// WriteInteger writes v to an open connection, encoded in the
+// connection's byte order. The size of T determines the number of
+// bytes written.
+func WriteInteger[T Integer](c *Conn, v T) error {
+	order, err := c.byteOrder()
+	if err != nil {
+		return err
+	}
+	return c.writeOrdered(order, int(unsafe.Sizeof(v)), uint64(v))
+}
@@ -0,0 +1,216 @@
+package i2c
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tempConn returns a connection, with byte order endian, whose reads
+// and writes use a temporary file. The file is also returned, so it
+// can be rewound.
+func tempConn(t *testing.T, endian binary.ByteOrder) (*Conn, *os.File) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(t.TempDir(), "regs"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newFileConn(f)
+	c.endian = endian
+	t.Cleanup(func() { c.Close() })
+	return c, f
+}
+
+// reversed returns a reversed copy of d.
+func reversed(d []byte) []byte {
+	r := make([]byte, len(d))
+	for i, b := range d {
+		r[len(d)-1-i] = b
+	}
+	return r
+}
+
+func TestSignedBoundaries(t *testing.T) {
+	vs := []struct {
+		name  string
+		val   int64
+		be    []byte
+		write func(c *Conn, v int64) error
+		read  func(c *Conn) (int64, error)
+	}{
+		{"int16 -1", -1, []byte{0xff, 0xff}, writeInt16, readInt16},
+		{"int16 min", math.MinInt16, []byte{0x80, 0x00}, writeInt16, readInt16},
+		{"int16 max", math.MaxInt16, []byte{0x7f, 0xff}, writeInt16, readInt16},
+		{"int16 0", 0, []byte{0x00, 0x00}, writeInt16, readInt16},
+		{"int32 -1", -1, []byte{0xff, 0xff, 0xff, 0xff}, writeInt32, readInt32},
+		{"int32 0x8000", 0x8000, []byte{0x00, 0x00, 0x80, 0x00}, writeInt32, readInt32},
+		{"int32 -0x8000", -0x8000, []byte{0xff, 0xff, 0x80, 0x00}, writeInt32, readInt32},
+		{"int32 min", math.MinInt32, []byte{0x80, 0x00, 0x00, 0x00}, writeInt32, readInt32},
+		{"int64 -1", -1, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, writeInt64, readInt64},
+		{"int64 0x8000", 0x8000, []byte{0, 0, 0, 0, 0, 0, 0x80, 0x00}, writeInt64, readInt64},
+		{"int64 min", math.MinInt64, []byte{0x80, 0, 0, 0, 0, 0, 0, 0}, writeInt64, readInt64},
+		{"int64 max", math.MaxInt64, []byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, writeInt64, readInt64},
+		{"generic int16", math.MinInt16, []byte{0x80, 0x00},
+			func(c *Conn, v int64) error { return WriteInteger(c, int16(v)) },
+			func(c *Conn) (int64, error) { v, err := ReadInteger[int16](c); return int64(v), err }},
+		{"generic int8", -1, []byte{0xff},
+			func(c *Conn, v int64) error { return WriteInteger(c, int8(v)) },
+			func(c *Conn) (int64, error) { v, err := ReadInteger[int8](c); return int64(v), err }},
+	}
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		for _, v := range vs {
+			c, f := tempConn(t, order)
+			want := v.be
+			if order == binary.LittleEndian {
+				want = reversed(v.be)
+			}
+			if err := v.write(c, v.val); err != nil {
+				t.Errorf("%v %s: write failed: %v", order, v.name, err)
+				continue
+			}
+			if d, _ := os.ReadFile(f.Name()); !bytes.Equal(d, want) {
+				t.Errorf("%v %s: wrote % x, want % x", order, v.name, d, want)
+			}
+			f.Seek(0, io.SeekStart)
+			if got, err := v.read(c); err != nil || got != v.val {
+				t.Errorf("%v %s: read %d, %v, want %d", order, v.name, got, err, v.val)
+			}
+			if _, err := v.read(c); err != io.EOF {
+				t.Errorf("%v %s: read at end got %v, want io.EOF", order, v.name, err)
+			}
+		}
+	}
+}
+
+func writeInt16(c *Conn, v int64) error { return c.WriteInt16(int16(v)) }
+func writeInt32(c *Conn, v int64) error { return c.WriteInt32(int32(v)) }
+func writeInt64(c *Conn, v int64) error { return c.WriteInt64(v) }
+
+func readInt16(c *Conn) (int64, error) {
+	v, err := c.ReadInt16()
+	return int64(v), err
+}
+
+func readInt32(c *Conn) (int64, error) {
+	v, err := c.ReadInt32()
+	return int64(v), err
+}
+
+func readInt64(c *Conn) (int64, error) {
+	return c.ReadInt64()
+}
+
+func TestSignedRawRegister(t *testing.T) {
+	// 0x8000 in a 16-bit register is the most negative value,
+	// whichever order its bytes arrive in.
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		c, f := tempConn(t, order)
+		raw := []byte{0x80, 0x00}
+		if order == binary.LittleEndian {
+			raw = reversed(raw)
+		}
+		f.Write(raw)
+		f.Seek(0, io.SeekStart)
+		if v, err := c.ReadInt16(); err != nil || v != math.MinInt16 {
+			t.Errorf("%v: got %d, %v, want %d", order, v, err, math.MinInt16)
+		}
+	}
+}
+
+func TestSignedTruncation(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, binary.LittleEndian)
+	a.devs[0x40].short = 1
+	if _, err := c.ReadInt16(); err != ErrTruncated {
+		t.Errorf("ReadInt16 got %v, want ErrTruncated", err)
+	}
+	if _, err := c.ReadInt32(); err != ErrTruncated {
+		t.Errorf("ReadInt32 got %v, want ErrTruncated", err)
+	}
+	if _, err := c.ReadInt64(); err != ErrTruncated {
+		t.Errorf("ReadInt64 got %v, want ErrTruncated", err)
+	}
+	if _, err := ReadInteger[int16](c); err != ErrTruncated {
+		t.Errorf("ReadInteger[int16] got %v, want ErrTruncated", err)
+	}
+	if _, err := c.ReadFloat64(); err != ErrTruncated {
+		t.Errorf("ReadFloat64 got %v, want ErrTruncated", err)
+	}
+}
+
+func TestFloatBits(t *testing.T) {
+	bits32 := []uint32{
+		0x7fc00000, // quiet NaN
+		0x7fa00001, // signalling NaN with a payload
+		0x7f800000, // +Inf
+		0xff800000, // -Inf
+		0x00000001, // smallest denormal
+		0x807fffff, // largest negative denormal
+		0x80000000, // -0
+		0x3f800000, // 1.0
+	}
+	bits64 := []uint64{
+		0x7ff8000000000000,
+		0x7ff4000000000001,
+		0x7ff0000000000000,
+		0xfff0000000000000,
+		0x0000000000000001,
+		0x800fffffffffffff,
+		0x8000000000000000,
+		0x3ff0000000000000,
+	}
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		for _, b := range bits32 {
+			c, f := tempConn(t, order)
+			if err := c.WriteFloat32(math.Float32frombits(b)); err != nil {
+				t.Errorf("%v %08x: write failed: %v", order, b, err)
+				continue
+			}
+			d, _ := os.ReadFile(f.Name())
+			if len(d) != 4 || order.Uint32(d) != b {
+				t.Errorf("%v %08x: wrote % x", order, b, d)
+			}
+			f.Seek(0, io.SeekStart)
+			if v, err := c.ReadFloat32(); err != nil || math.Float32bits(v) != b {
+				t.Errorf("%v %08x: read %08x, %v", order, b, math.Float32bits(v), err)
+			}
+		}
+		for _, b := range bits64 {
+			c, f := tempConn(t, order)
+			if err := c.WriteFloat64(math.Float64frombits(b)); err != nil {
+				t.Errorf("%v %016x: write failed: %v", order, b, err)
+				continue
+			}
+			d, _ := os.ReadFile(f.Name())
+			if len(d) != 8 || order.Uint64(d) != b {
+				t.Errorf("%v %016x: wrote % x", order, b, d)
+			}
+			f.Seek(0, io.SeekStart)
+			if v, err := c.ReadFloat64(); err != nil || math.Float64bits(v) != b {
+				t.Errorf("%v %016x: read %016x, %v", order, b, math.Float64bits(v), err)
+			}
+		}
+	}
+}
+
+func TestIntegerWidths(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	type reg uint16
+	if err := WriteInteger(c, reg(0x1234)); err != nil {
+		t.Fatalf("WriteInteger failed: %v", err)
+	}
+	if got := a.ops(); len(got) != 1 || got[0] != "w 40: 12 34" {
+		t.Errorf("got %q, want 2 bytes written", got)
+	}
+	if err := WriteInteger(c, uint64(1)); err != nil {
+		t.Fatalf("WriteInteger failed: %v", err)
+	}
+	if got := a.ops(); len(got) != 1 || got[0] != "w 40: 00 00 00 00 00 00 00 01" {
+		t.Errorf("got %q, want 8 bytes written", got)
+	}
+}
@@ -0,0 +1,54 @@
+package i2c
+
+import "fmt"
+
+// SetPreamble configures a sequence of bytes, for example an unlock
+// token, that is written to the device ahead of every register
+// access: those of the register helpers, such as ReadReg, ReadRegBuf,
+// Regs, WriteReg, SetRegs, UpdateReg and ReadLarge, and of WriteRead.
+// The preamble and the access form one combined transaction, with
+// repeated starts between them, so no other transaction can
+// intervene. An SMBus transaction cannot carry a preamble, so while
+// one is set the SMBus register helpers, such as SMBusReadByteData,
+// return an error wrapping ErrUnsupported. Plain reads and writes are
+// unaffected. An empty seq is equivalent to ClearPreamble.
+func (c *Conn) SetPreamble(seq []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(seq) == 0 {
+		c.preamble = nil
+		return
+	}
+	c.preamble = append([]byte(nil), seq...)
+}
+
+// ClearPreamble stops register accesses being preceded by a preamble.
+func (c *Conn) ClearPreamble() {
+	c.SetPreamble(nil)
+}
+
+// preambled writes the connection's preamble and then w, and reads
+// len(r) bytes into r when r is not empty, all as one combined
+// transaction. The number of bytes read is returned. The caller must
+// hold c.mu.
+func (c *Conn) preambled(w, r []byte) (int, error) {
+	fl := c.flags()
+	msgs := []Message{
+		{Addr: c.addr, Flags: fl, Buf: c.preamble},
+		{Addr: c.addr, Flags: fl, Buf: w},
+	}
+	if len(r) != 0 {
+		msgs = append(msgs, Message{Addr: c.addr, Flags: fl | M_RD, Buf: r})
+	}
+	n, err := c.transaction(msgs)
+	if err != nil {
+		return 0, err
+	}
+	if n != len(msgs) {
+		return 0, fmt.Errorf("transferred %d of %d messages: %w", n, len(msgs), ErrTruncated)
+	}
+	return len(r), nil
+}
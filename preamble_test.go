@@ -0,0 +1,85 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestPreamble(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	c.SetPreamble([]byte{0xaa, 0x55})
+	buf := make([]byte, 2)
+	vs := []struct {
+		name string
+		fn   func() error
+		want []string
+	}{
+		{"ReadReg", func() error {
+			_, err := c.ReadReg(0x10, buf)
+			return err
+		}, []string{"rdwr 40 w aa 55; 40 w 10; 40 r 2"}},
+		{"ReadRegBuf", func() error {
+			_, err := c.ReadRegBuf(0x10, buf)
+			return err
+		}, []string{"rdwr 40 w aa 55; 40 w 10; 40 r 2"}},
+		{"ReadRegBytes", func() error {
+			_, err := c.ReadRegBytes(0x10, 3)
+			return err
+		}, []string{"rdwr 40 w aa 55; 40 w 10; 40 r 3"}},
+		{"RegN", func() error {
+			_, err := c.RegN(0x10, 1)
+			return err
+		}, []string{"rdwr 40 w aa 55; 40 w 10; 40 r 1"}},
+		{"Reg16", func() error {
+			_, err := c.Reg16(0x0123)
+			return err
+		}, []string{"rdwr 40 w aa 55; 40 w 01 23; 40 r 1"}},
+		{"WriteRead", func() error {
+			_, err := c.WriteRead([]byte{0x20}, buf)
+			return err
+		}, []string{"rdwr 40 w aa 55; 40 w 20; 40 r 2"}},
+		{"WriteReg", func() error {
+			_, err := c.WriteReg(0x10, []byte{1, 2})
+			return err
+		}, []string{"rdwr 40 w aa 55; 40 w 10 01 02"}},
+		{"SetRegs", func() error {
+			return c.SetRegs(0x10, []byte{3})
+		}, []string{"rdwr 40 w aa 55; 40 w 10 03"}},
+		{"UpdateReg", func() error {
+			_, err := c.UpdateReg(0x10, 0xf0, 0x50)
+			return err
+		}, []string{"rdwr 40 w aa 55; 40 w 10; 40 r 1", "rdwr 40 w aa 55; 40 w 10 53"}},
+		{"ReadLarge", func() error {
+			_, err := c.ReadLarge(0x0100, 4, 2, nil)
+			return err
+		}, []string{"rdwr 40 w aa 55; 40 w 01 00; 40 r 2", "rdwr 40 w aa 55; 40 w 01 02; 40 r 2"}},
+	}
+	for _, v := range vs {
+		if err := v.fn(); err != nil {
+			t.Errorf("%s failed: %v", v.name, err)
+		}
+		if got := a.ops(); !equalStrings(got, v.want) {
+			t.Errorf("%s: got %q, want %q", v.name, got, v.want)
+		}
+	}
+
+	if _, err := c.SMBusReadByteData(0x10); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("SMBusReadByteData got %v, want ErrUnsupported", err)
+	}
+	if err := c.SMBusWriteWordData(0x10, 1); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("SMBusWriteWordData got %v, want ErrUnsupported", err)
+	}
+	if ops := a.ops(); len(ops) != 0 {
+		t.Errorf("SMBus helpers accessed the bus: %q", ops)
+	}
+
+	c.ClearPreamble()
+	if _, err := c.ReadReg(0x10, buf); err != nil {
+		t.Fatalf("ReadReg failed: %v", err)
+	}
+	if got, want := a.ops(), []string{"rdwr 40 w 10; 40 r 2"}; !equalStrings(got, want) {
+		t.Errorf("after ClearPreamble got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+package i2c
+
+import "time"
+
+// Quirks describes how a device departs from the i2c specification,
+// and how the connection accommodates it. The combined transactions
+// of Transaction, and the helpers built on it such as WriteRead and
+// ReadReg, consult them. The zero value describes a compliant device.
+type Quirks struct {
+	// NoRepeatedStart performs each message of a combined
+	// transaction as a separate transaction, with a stop and a
+	// start between messages. The bus is released between them, so
+	// another master may intervene.
+	NoRepeatedStart bool
+
+	// RequiresStopBetween keeps the messages of a combined
+	// transaction together, but issues a stop after each one
+	// (M_STOP) rather than a repeated start. The adapter must
+	// support FUNC_PROTOCOL_MANGLING.
+	RequiresStopBetween bool
+
+	// IgnoreNAK continues a message that the device does not
+	// acknowledge (M_IGNORE_NAK), rather than abandoning the
+	// transaction. The adapter must support FUNC_PROTOCOL_MANGLING.
+	IgnoreNAK bool
+
+	// ExtraClockLow is only a sleep before every transaction, and
+	// before every message that NoRepeatedStart separates. It does
+	// not change the bus clock, which i2c-dev offers no control
+	// of, but it gives slow devices that stretch the clock poorly
+	// more time for their internal processing between transactions.
+	ExtraClockLow time.Duration
+}
+
+// SetQuirks configures the connection to accommodate a device that is
+// not fully compliant with the i2c specification.
+func (c *Conn) SetQuirks(q Quirks) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	c.quirks = q
+	return nil
+}
+
+// quirkFlags returns the message flags called for by the quirks of
+// c. The caller must hold c.mu.
+func (c *Conn) quirkFlags() MsgFlag {
+	var fl MsgFlag
+	if c.quirks.RequiresStopBetween {
+		fl |= M_STOP
+	}
+	if c.quirks.IgnoreNAK {
+		fl |= M_IGNORE_NAK
+	}
+	return fl
+}
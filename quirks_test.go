@@ -0,0 +1,96 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuirks(t *testing.T) {
+	vs := []struct {
+		name string
+		q    Quirks
+		want []string
+	}{
+		{"none", Quirks{}, []string{"rdwr 40 w 10; 40 r 2"}},
+		{"no repeated start", Quirks{NoRepeatedStart: true}, []string{"rdwr 40 w 10", "rdwr 40 r 2"}},
+		{"stop between", Quirks{RequiresStopBetween: true}, []string{"rdwr 40 w 10 M_STOP; 40 r 2 M_STOP"}},
+		{"ignore nak", Quirks{IgnoreNAK: true}, []string{"rdwr 40 w 10 M_IGNORE_NAK; 40 r 2 M_IGNORE_NAK"}},
+		{"all", Quirks{NoRepeatedStart: true, RequiresStopBetween: true, IgnoreNAK: true}, []string{"rdwr 40 w 10 M_IGNORE_NAK|M_STOP", "rdwr 40 r 2 M_IGNORE_NAK|M_STOP"}},
+	}
+	for _, v := range vs {
+		a := newFakeAdapter(0x40)
+		a.devs[0x40].regs[0x10], a.devs[0x40].regs[0x11] = 0x12, 0x34
+		c := newFakeConn(t, a, 0x40, binary.BigEndian)
+		if err := c.SetQuirks(v.q); err != nil {
+			t.Fatalf("%s: SetQuirks failed: %v", v.name, err)
+		}
+		r := make([]byte, 2)
+		if _, err := c.WriteRead([]byte{0x10}, r); err != nil {
+			t.Errorf("%s: WriteRead failed: %v", v.name, err)
+		} else if r[0] != 0x12 || r[1] != 0x34 {
+			t.Errorf("%s: read % x, want 12 34", v.name, r)
+		}
+		if got := a.ops(); !equalStrings(got, v.want) {
+			t.Errorf("%s: got %q, want %q", v.name, got, v.want)
+		}
+	}
+}
+
+func TestIgnoreNAK(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	a.devs[0x40].nak = true
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	if _, err := c.WriteRead([]byte{0x10}, make([]byte, 1)); err == nil {
+		t.Error("WriteRead to a NACKing device succeeded")
+	}
+	c.SetQuirks(Quirks{IgnoreNAK: true})
+	if _, err := c.WriteRead([]byte{0x10}, make([]byte, 1)); err != nil {
+		t.Errorf("WriteRead ignoring NACKs failed: %v", err)
+	}
+}
+
+func TestQuirksUnsupported(t *testing.T) {
+	for _, q := range []Quirks{{RequiresStopBetween: true}, {IgnoreNAK: true}} {
+		a := newFakeAdapter(0x40)
+		a.funcs &^= FUNC_PROTOCOL_MANGLING
+		c := newFakeConn(t, a, 0x40, binary.BigEndian)
+		c.SetQuirks(q)
+		if _, err := c.WriteRead([]byte{0x10}, make([]byte, 1)); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%+v: got %v, want ErrUnsupported", q, err)
+		}
+		if ops := a.ops(); len(ops) != 0 {
+			t.Errorf("%+v: unsupported quirk touched the bus: %q", q, ops)
+		}
+	}
+}
+
+func TestExtraClockLow(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	for _, v := range []struct {
+		q     Quirks
+		sleep time.Duration
+	}{
+		{Quirks{ExtraClockLow: delay}, delay},
+		{Quirks{ExtraClockLow: delay, NoRepeatedStart: true}, 2 * delay},
+	} {
+		a := newFakeAdapter(0x40)
+		c := newFakeConn(t, a, 0x40, binary.BigEndian)
+		c.SetQuirks(v.q)
+		start := time.Now()
+		if _, err := c.WriteRead([]byte{0x10}, make([]byte, 1)); err != nil {
+			t.Fatalf("%+v: WriteRead failed: %v", v.q, err)
+		}
+		if d := time.Since(start); d < v.sleep {
+			t.Errorf("%+v: took %v, want at least %v", v.q, d, v.sleep)
+		}
+		start = time.Now()
+		if _, err := c.Write([]byte{0x10}); err != nil {
+			t.Fatalf("%+v: Write failed: %v", v.q, err)
+		}
+		if d := time.Since(start); d < delay {
+			t.Errorf("%+v: plain write took %v, want at least %v", v.q, d, delay)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package i2c
+
+import "time"
+
+// now and sleep are the clock used to pace and time transactions.
+// Tests replace them to simulate the passage of time.
+var (
+	now   = time.Now
+	sleep = time.Sleep
+)
+
+// limiter is a token bucket, holding at most one token, that paces
+// transactions to a maximum rate.
+type limiter struct {
+	interval time.Duration
+	// next is when the next token becomes available.
+	next time.Time
+}
+
+// wait blocks until a token is available and consumes it.
+func (l *limiter) wait() {
+	t := now()
+	if d := l.next.Sub(t); d > 0 {
+		sleep(d)
+		t = l.next
+	}
+	l.next = t.Add(l.interval)
+}
+
+// pace blocks until the connection's rate limit, if any, permits
+// another transaction, and then for any ExtraClockLow quirk. The
+// caller must hold c.mu.
+func (c *Conn) pace() {
+	if c.limit != nil {
+		c.limit.wait()
+	}
+	if c.quirks.ExtraClockLow > 0 {
+		time.Sleep(c.quirks.ExtraClockLow)
+	}
+}
+
+// SetRateLimit paces the transactions performed on the connection so
+// that no more than maxPerSecond of them occur in any second. This
+// protects slow devices, and shared buses, from an over-eager
+// poller. When over the limit, the calling goroutine blocks until the
+// transaction is permitted. A maxPerSecond value of zero or less
+// removes the limit.
+func (c *Conn) SetRateLimit(maxPerSecond int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if maxPerSecond <= 0 {
+		c.limit = nil
+		return
+	}
+	c.limit = &limiter{interval: time.Second / time.Duration(maxPerSecond)}
+}
@@ -0,0 +1,82 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSetRateLimit(t *testing.T) {
+	clk := useClock(t)
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	var at []time.Duration
+	start := clk.now()
+	a.devs[0x40].onRead = func(buf []byte) {
+		at = append(at, clk.now().Sub(start))
+	}
+	read := func(n int) {
+		for i := 0; i < n; i++ {
+			if _, err := c.ReadUint8(); err != nil {
+				t.Fatalf("ReadUint8 failed: %v", err)
+			}
+		}
+	}
+
+	c.SetRateLimit(10)
+	read(3)
+	want := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond}
+	if len(at) != len(want) {
+		t.Fatalf("got %d reads, want %d", len(at), len(want))
+	}
+	for i := range want {
+		if at[i] != want[i] {
+			t.Errorf("read %d at %v, want %v", i, at[i], want[i])
+		}
+	}
+
+	// A device left idle for longer than the interval is not paced.
+	clk.sleep(time.Second)
+	clk.sleeps, at = nil, nil
+	read(1)
+	if len(clk.sleeps) != 0 {
+		t.Errorf("idle connection was paced: %v", clk.sleeps)
+	}
+
+	c.SetRateLimit(0)
+	read(5)
+	if len(clk.sleeps) != 0 {
+		t.Errorf("unlimited connection was paced: %v", clk.sleeps)
+	}
+}
+
+func TestTimedTransaction(t *testing.T) {
+	clk := useClock(t)
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	d, err := c.TimedTransaction(func(c *Conn) error {
+		sleep(15 * time.Millisecond)
+		return c.WriteUint8(1)
+	})
+	if err != nil || d != 15*time.Millisecond {
+		t.Errorf("got %v, %v, want 15ms", d, err)
+	}
+	if len(clk.sleeps) != 1 {
+		t.Errorf("got sleeps %v, want one", clk.sleeps)
+	}
+
+	a.devs[0x40].nak = true
+	d, err = c.TimedTransaction(func(c *Conn) error {
+		sleep(time.Millisecond)
+		return c.WriteUint8(1)
+	})
+	if !errors.Is(err, syscall.ENXIO) || d != time.Millisecond {
+		t.Errorf("got %v, %v, want 1ms and ENXIO", d, err)
+	}
+	var nilConn *Conn
+	if _, err := nilConn.TimedTransaction(func(*Conn) error { return nil }); err != ErrInvalid {
+		t.Errorf("nil connection got %v, want ErrInvalid", err)
+	}
+}
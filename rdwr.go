@@ -0,0 +1,292 @@
+package i2c
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// MsgFlag holds the flags of a Message.
+type MsgFlag uint16
+
+// M_RD etc are the i2c message flags from /usr/include/linux/i2c.h.
+const (
+	M_RD           MsgFlag = 0x0001
+	M_TEN          MsgFlag = 0x0010
+	M_RECV_LEN     MsgFlag = 0x0400
+	M_NO_RD_ACK    MsgFlag = 0x0800
+	M_IGNORE_NAK   MsgFlag = 0x1000
+	M_REV_DIR_ADDR MsgFlag = 0x2000
+	M_NOSTART      MsgFlag = 0x4000
+	M_STOP         MsgFlag = 0x8000
+)
+
+// flagNames holds the names of the MsgFlag bits, and the adapter
+// functionality, if any, each of them needs.
+var flagNames = []struct {
+	bit      MsgFlag
+	name     string
+	need     uint64
+	needName string
+}{
+	{M_RD, "M_RD", 0, ""},
+	{M_TEN, "M_TEN", FUNC_10BIT_ADDR, "FUNC_10BIT_ADDR"},
+	{M_RECV_LEN, "M_RECV_LEN", 0, ""},
+	{M_NO_RD_ACK, "M_NO_RD_ACK", FUNC_PROTOCOL_MANGLING, "FUNC_PROTOCOL_MANGLING"},
+	{M_IGNORE_NAK, "M_IGNORE_NAK", FUNC_PROTOCOL_MANGLING, "FUNC_PROTOCOL_MANGLING"},
+	{M_REV_DIR_ADDR, "M_REV_DIR_ADDR", FUNC_PROTOCOL_MANGLING, "FUNC_PROTOCOL_MANGLING"},
+	{M_NOSTART, "M_NOSTART", FUNC_NOSTART, "FUNC_NOSTART"},
+	{M_STOP, "M_STOP", FUNC_PROTOCOL_MANGLING, "FUNC_PROTOCOL_MANGLING"},
+}
+
+// String lists the names of the set MsgFlag bits. Any unnamed bits
+// are listed in hex.
+func (f MsgFlag) String() string {
+	var s []string
+	for _, n := range flagNames {
+		if f&n.bit != 0 {
+			s = append(s, n.name)
+			f &^= n.bit
+		}
+	}
+	if f != 0 || len(s) == 0 {
+		s = append(s, fmt.Sprintf("%#04x", uint16(f)))
+	}
+	return strings.Join(s, "|")
+}
+
+// check confirms that an adapter with functionality funcs can perform
+// a message with flags f.
+func (f MsgFlag) check(funcs uint64) error {
+	for _, n := range flagNames {
+		if f&n.bit != 0 && funcs&n.need != n.need {
+			return fmt.Errorf("%s needs an adapter with %s: %w", n.name, n.needName, ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// RDWR_IOCTL_MAX_MSGS is the largest number of messages the kernel
+// accepts in one combined transaction.
+const RDWR_IOCTL_MAX_MSGS = 42
+
+// Message is one message of a combined transaction. Addr is the
+// device address and Flags a combination of the M_* flags. Buf holds
+// the data to write or, for an M_RD message, receives the data read.
+type Message struct {
+	Addr  uint
+	Flags MsgFlag
+	Buf   []byte
+}
+
+// Msg is an alternative name for Message.
+type Msg = Message
+
+// i2cMsg mirrors the kernel's struct i2c_msg.
+type i2cMsg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   *byte
+}
+
+// i2cRdwrIoctlData mirrors the kernel's struct i2c_rdwr_ioctl_data.
+type i2cRdwrIoctlData struct {
+	msgs  *i2cMsg
+	nmsgs uint32
+}
+
+// The kernel ABI places buf after three 16-bit fields, padded to
+// pointer alignment, on both 32- and 64-bit platforms. These
+// declarations fail to compile if the layouts above do not match it.
+var (
+	_ = [1]struct{}{}[unsafe.Offsetof(i2cMsg{}.buf)-8]
+	_ = [1]struct{}{}[unsafe.Sizeof(i2cMsg{})-8-unsafe.Sizeof(uintptr(0))]
+	_ = [1]struct{}{}[unsafe.Offsetof(i2cRdwrIoctlData{}.nmsgs)-unsafe.Sizeof(uintptr(0))]
+)
+
+// transaction performs msgs as a single combined transaction,
+// returning the number of messages the kernel reports as transferred.
+// The caller must hold c.mu.
+func (c *Conn) transaction(msgs []Message) (int, error) {
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+	if len(msgs) > RDWR_IOCTL_MAX_MSGS {
+		return 0, fmt.Errorf("%d messages exceeds limit of %d: %w", len(msgs), RDWR_IOCTL_MAX_MSGS, ErrInvalid)
+	}
+	funcs, err := c.adapterFuncs()
+	if err == nil && funcs&FUNC_I2C == 0 {
+		return 0, fmt.Errorf("combined transaction on bus %q: %w", c.bus, ErrUnsupported)
+	}
+	known := err == nil
+	ms := make([]i2cMsg, len(msgs))
+	qf := c.quirkFlags()
+	for i, m := range msgs {
+		if len(m.Buf) > 0xffff || m.Addr > 0x3ff {
+			return 0, fmt.Errorf("message %d: %w", i, ErrInvalid)
+		}
+		fl := m.Flags | qf
+		if known {
+			if err := fl.check(funcs); err != nil {
+				return 0, fmt.Errorf("message %d on bus %q: %w", i, c.bus, err)
+			}
+		}
+		ms[i] = i2cMsg{
+			addr:  uint16(m.Addr),
+			flags: uint16(fl),
+			len:   uint16(len(m.Buf)),
+		}
+		if len(m.Buf) != 0 {
+			ms[i].buf = &m.Buf[0]
+		}
+	}
+	if !c.quirks.NoRepeatedStart {
+		return c.rdwr(ms)
+	}
+	done := 0
+	for i := range ms {
+		n, err := c.rdwr(ms[i : i+1])
+		done += n
+		if err != nil || n != 1 {
+			return done, err
+		}
+	}
+	return done, nil
+}
+
+// rdwr issues ms as one RDWR ioctl. The caller must hold c.mu.
+func (c *Conn) rdwr(ms []i2cMsg) (int, error) {
+	args := i2cRdwrIoctlData{msgs: &ms[0], nmsgs: uint32(len(ms))}
+	c.pace()
+	return c.ioctlPtrRet(RDWR, unsafe.Pointer(&args))
+}
+
+// Transaction performs msgs as a single combined i2c transaction,
+// with a repeated start, rather than a stop and start, between the
+// messages. This is how many devices expect a register pointer write
+// and the read that follows it to be performed. The buffers of read
+// messages are filled in place. The kernel limits a transaction to
+// RDWR_IOCTL_MAX_MSGS messages. Adapters that only support SMBus
+// cannot perform combined transactions, and an error wrapping
+// ErrUnsupported is returned for them, as it is for messages with
+// flags the adapter cannot honour, for example M_NOSTART without
+// FUNC_NOSTART. Such errors are returned before the bus is touched.
+// See SetQuirks for accommodating devices that do not support
+// repeated starts.
+func (c *Conn) Transaction(msgs []Message) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	_, err := c.transaction(msgs)
+	return err
+}
+
+// Transfer performs msgs as a single combined transaction, as for
+// Transaction. The messages may address different devices, for
+// example a mux and a device behind it, and the bus is held for the
+// whole sequence.
+func (c *Conn) Transfer(msgs ...Msg) error {
+	return c.Transaction(msgs)
+}
+
+// Transact performs msgs as a single combined transaction, as for
+// Transaction, and reports the number of bytes transferred by each
+// message by reslicing its Buf. For an M_RD|M_RECV_LEN message, whose
+// length is given by the first byte the device sends, as in an SMBus
+// block read, the first byte of Buf is the number of bytes expected
+// beyond the data. If zero, it is set to 1, for the length byte; set
+// it to 2 when the device also sends a PEC byte. Buf must hold these
+// bytes and the largest SMBus block of 32 bytes, so at least 33 or 34
+// bytes. Such a Buf is resliced to the length byte, the data and any
+// extra bytes. Other messages transfer all of Buf.
+func (c *Conn) Transact(msgs []Msg) error {
+	for i := range msgs {
+		m := &msgs[i]
+		if m.Flags&M_RECV_LEN == 0 {
+			continue
+		}
+		if m.Flags&M_RD == 0 || len(m.Buf) == 0 {
+			return fmt.Errorf("message %d: receive length needs a read: %w", i, ErrInvalid)
+		}
+		if m.Buf[0] == 0 {
+			m.Buf[0] = 1
+		}
+		if want := smbusBlockMax + int(m.Buf[0]); len(m.Buf) < want {
+			return fmt.Errorf("message %d: receive length needs a %d byte read: %w", i, want, ErrInvalid)
+		}
+	}
+	extra := make([]int, len(msgs))
+	for i, m := range msgs {
+		if m.Flags&M_RECV_LEN != 0 {
+			extra[i] = int(m.Buf[0])
+		}
+	}
+	if err := c.Transaction(msgs); err != nil {
+		return err
+	}
+	for i := range msgs {
+		m := &msgs[i]
+		if m.Flags&M_RECV_LEN == 0 {
+			continue
+		}
+		n := int(m.Buf[0]) + extra[i]
+		if n > len(m.Buf) {
+			n = len(m.Buf)
+		}
+		m.Buf = m.Buf[:n]
+	}
+	return nil
+}
+
+// flags returns the message flags needed to address the device of c.
+func (c *Conn) flags() MsgFlag {
+	if c.tenBit {
+		return M_TEN
+	}
+	return 0
+}
+
+// WriteRead writes w to the device and then reads len(r) bytes into
+// r, as a single combined transaction with a repeated start between
+// the write and the read. This is the common way to read from a
+// register: w holds the register pointer. The device address, and
+// whether it is a 10-bit one, are those of the connection. The number
+// of bytes read is returned, along with an error wrapping ErrTruncated
+// if the transaction was not completed. An error wrapping
+// ErrUnsupported is returned if the adapter only supports SMBus.
+func (c *Conn) WriteRead(w []byte, r []byte) (int, error) {
+	if len(w) > maxMsg || len(r) > maxMsg {
+		return 0, ErrInvalid
+	}
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	return c.writeRead(w, r)
+}
+
+// writeRead performs WriteRead, preceded by any preamble. The caller
+// must hold c.mu.
+func (c *Conn) writeRead(w, r []byte) (int, error) {
+	if c.preamble != nil {
+		return c.preambled(w, r)
+	}
+	fl := c.flags()
+	msgs := []Message{
+		{Addr: c.addr, Flags: fl, Buf: w},
+		{Addr: c.addr, Flags: fl | M_RD, Buf: r},
+	}
+	if len(w) == 0 {
+		msgs = msgs[1:]
+	}
+	n, err := c.transaction(msgs)
+	if err != nil {
+		return 0, err
+	}
+	if n != len(msgs) {
+		return 0, fmt.Errorf("transferred %d of %d messages: %w", n, len(msgs), ErrTruncated)
+	}
+	return len(r), nil
+}
@@ -0,0 +1,129 @@
+package i2c
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestRdwrABI(t *testing.T) {
+	// Golden values of the kernel's struct i2c_msg and struct
+	// i2c_rdwr_ioctl_data, by pointer size.
+	golden := map[uintptr]struct {
+		msgSize, bufOffset, dataSize, nmsgsOffset uintptr
+	}{
+		4: {12, 8, 8, 4},
+		8: {16, 8, 16, 8},
+	}
+	ptr := unsafe.Sizeof(uintptr(0))
+	g, ok := golden[ptr]
+	if !ok {
+		t.Fatalf("no golden values for %d byte pointers", ptr)
+	}
+	vs := []struct {
+		name      string
+		got, want uintptr
+	}{
+		{"sizeof(i2c_msg)", unsafe.Sizeof(i2cMsg{}), g.msgSize},
+		{"offsetof(i2c_msg.addr)", unsafe.Offsetof(i2cMsg{}.addr), 0},
+		{"offsetof(i2c_msg.flags)", unsafe.Offsetof(i2cMsg{}.flags), 2},
+		{"offsetof(i2c_msg.len)", unsafe.Offsetof(i2cMsg{}.len), 4},
+		{"offsetof(i2c_msg.buf)", unsafe.Offsetof(i2cMsg{}.buf), g.bufOffset},
+		{"sizeof(i2c_rdwr_ioctl_data)", unsafe.Sizeof(i2cRdwrIoctlData{}), g.dataSize},
+		{"offsetof(i2c_rdwr_ioctl_data.msgs)", unsafe.Offsetof(i2cRdwrIoctlData{}.msgs), 0},
+		{"offsetof(i2c_rdwr_ioctl_data.nmsgs)", unsafe.Offsetof(i2cRdwrIoctlData{}.nmsgs), g.nmsgsOffset},
+	}
+	for _, v := range vs {
+		if v.got != v.want {
+			t.Errorf("%s: got %d, want %d", v.name, v.got, v.want)
+		}
+	}
+}
+
+func TestTransactRecvLen(t *testing.T) {
+	a := newFakeAdapter(0x0b)
+	a.devs[0x0b].blocks[0x20] = []byte("pack")
+	c := newFakeConn(t, a, 0x0b, binary.LittleEndian)
+	vs := []struct {
+		extra, size int
+		want        []byte
+		ok          bool
+	}{
+		{0, 32, nil, false},
+		{0, 33, []byte("\x04pack"), true},
+		{2, 33, nil, false},
+		{2, 34, []byte("\x04pack\x00"), true},
+	}
+	for _, v := range vs {
+		buf := make([]byte, v.size)
+		buf[0] = byte(v.extra)
+		msgs := []Msg{
+			{Addr: 0x0b, Buf: []byte{0x20}},
+			{Addr: 0x0b, Flags: M_RD | M_RECV_LEN, Buf: buf},
+		}
+		err := c.Transact(msgs)
+		if !v.ok {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("extra=%d size=%d: got %v, want ErrInvalid", v.extra, v.size, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("extra=%d size=%d: failed: %v", v.extra, v.size, err)
+		} else if !bytes.Equal(msgs[1].Buf, v.want) {
+			t.Errorf("extra=%d size=%d: got %q, want %q", v.extra, v.size, msgs[1].Buf, v.want)
+		}
+	}
+	if ops := a.ops(); len(ops) != 2 {
+		t.Errorf("invalid messages reached the bus: %q", ops)
+	}
+}
+
+func TestMsgFlagString(t *testing.T) {
+	vs := []struct {
+		f    MsgFlag
+		want string
+	}{
+		{0, "0x0000"},
+		{M_RD, "M_RD"},
+		{M_RD | M_RECV_LEN, "M_RD|M_RECV_LEN"},
+		{M_NOSTART | 0x0100, "M_NOSTART|0x0100"},
+	}
+	for _, v := range vs {
+		if got := v.f.String(); got != v.want {
+			t.Errorf("%#04x: got %q, want %q", uint16(v.f), got, v.want)
+		}
+	}
+}
+
+func TestTransactionFlagCheck(t *testing.T) {
+	a := newFakeAdapter(0x50)
+	a.funcs = FUNC_I2C
+	c := newFakeConn(t, a, 0x50, binary.BigEndian)
+	vs := []struct {
+		f    MsgFlag
+		need string
+	}{
+		{M_NOSTART, "FUNC_NOSTART"},
+		{M_TEN, "FUNC_10BIT_ADDR"},
+		{M_IGNORE_NAK, "FUNC_PROTOCOL_MANGLING"},
+		{M_STOP, "FUNC_PROTOCOL_MANGLING"},
+		{M_NO_RD_ACK | M_RD, "FUNC_PROTOCOL_MANGLING"},
+		{M_REV_DIR_ADDR, "FUNC_PROTOCOL_MANGLING"},
+	}
+	for _, v := range vs {
+		err := c.Transaction([]Message{{Addr: 0x50, Flags: v.f, Buf: []byte{0}}})
+		if !errors.Is(err, ErrUnsupported) || !strings.Contains(err.Error(), v.need) {
+			t.Errorf("%v: got %v, want ErrUnsupported naming %s", v.f, err, v.need)
+		}
+	}
+	if ops := a.ops(); len(ops) != 0 {
+		t.Errorf("unsupported messages reached the bus: %q", ops)
+	}
+	if err := c.Transaction([]Message{{Addr: 0x50, Flags: M_RD, Buf: []byte{0}}}); err != nil {
+		t.Errorf("supported message failed: %v", err)
+	}
+}
@@ -0,0 +1,497 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// readUint reads an n byte unsigned integer starting at register reg,
+// decoded in the connection's byte order.
+func (c *Conn) readUint(reg byte, n int) (uint64, error) {
+	if n < 1 || n > 8 {
+		return 0, fmt.Errorf("%d byte value is unsupported: %w", n, ErrInvalid)
+	}
+	order, err := c.byteOrder()
+	if err != nil {
+		return 0, err
+	}
+	var d [8]byte
+	if _, err := c.ReadRegBuf(reg, d[:n]); err != nil {
+		return 0, err
+	}
+	return decodeUint(order, d[:n]), nil
+}
+
+// ReadBiased reads an n byte unsigned value starting at register reg
+// and returns it less bias. Some devices, temperature sensors in
+// particular, store a value offset by a fixed bias rather than in two's
+// complement form. The value is decoded in the connection's byte
+// order, and n may be 1 through 8.
+func (c *Conn) ReadBiased(reg byte, n int, bias int64) (int64, error) {
+	u, err := c.readUint(reg, n)
+	if err != nil {
+		return 0, err
+	}
+	return int64(u) - bias, nil
+}
+
+// writeRegs writes vals to the device starting at register reg. With
+// a preamble configured, the preamble and the write form one combined
+// transaction. The caller must hold c.mu.
+func (c *Conn) writeRegs(reg byte, vals []byte) error {
+	d := append([]byte{reg}, vals...)
+	if c.preamble != nil {
+		_, err := c.preambled(d, nil)
+		return err
+	}
+	if n, err := c.write(d); err != nil {
+		return err
+	} else if n != len(d) {
+		return &TruncationError{Op: "write", N: n, Want: len(d)}
+	}
+	return nil
+}
+
+// updateReg replaces the mask selected bits of register reg with
+// those of val, returning the prior value of the register. The write
+// is skipped if it would not change the register. The caller must
+// hold c.mu.
+func (c *Conn) updateReg(reg, mask, val byte) (byte, error) {
+	var d [1]byte
+	if _, err := c.readReg(reg, d[:]); err != nil {
+		return 0, err
+	}
+	old := d[0]
+	v := (old &^ mask) | (val & mask)
+	if v == old {
+		return old, nil
+	}
+	return old, c.writeRegs(reg, []byte{v})
+}
+
+// UpdateReg replaces the mask selected bits of register reg with those
+// of value, leaving the other bits unchanged, and returns the previous
+// value of the register, so callers can log transitions. The write is
+// skipped if the register already holds the result. The connection is
+// held across the read and the write, so no other use of it can
+// intervene.
+func (c *Conn) UpdateReg(reg, mask, value byte) (byte, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	return c.updateReg(reg, mask, value)
+}
+
+// ReadFIFO drains count records, each of recordSize bytes, from the
+// FIFO data register reg in a single read. The records are returned
+// as separate slices of one underlying buffer. A count of zero reads
+// nothing. If the device returns a partial record, the complete
+// records are returned along with ErrTruncated.
+func (c *Conn) ReadFIFO(reg byte, recordSize, count int) ([][]byte, error) {
+	if recordSize < 1 || count < 0 || recordSize*count > maxMsg {
+		return nil, ErrInvalid
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	d := make([]byte, recordSize*count)
+	n, err := c.ReadRegBuf(reg, d)
+	if err != nil && !errors.Is(err, ErrTruncated) {
+		return nil, err
+	}
+	recs := make([][]byte, 0, n/recordSize)
+	for i := 0; i+recordSize <= n; i += recordSize {
+		recs = append(recs, d[i:i+recordSize:i+recordSize])
+	}
+	return recs, err
+}
+
+// ReadLarge reads n bytes from a device, such as a large EEPROM, with
+// a 16-bit big endian address pointer starting at addr. The data is
+// read in pieces of up to chunk bytes, the address pointer being
+// rewritten for each one, and progress, if not nil, is called after
+// each piece with the number of bytes read so far and n.
+func (c *Conn) ReadLarge(addr uint16, n int, chunk int, progress func(done, total int)) ([]byte, error) {
+	if n < 0 || chunk < 1 || chunk > maxMsg || n > 0x10000-int(addr) {
+		return nil, ErrInvalid
+	}
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	d := make([]byte, n)
+	for done := 0; done < n; {
+		size := chunk
+		if rem := n - done; rem < size {
+			size = rem
+		}
+		at := int(addr) + done
+		c.ptr[0], c.ptr[1] = byte(at>>8), byte(at)
+		j, err := c.readPtr(c.ptr[:2], d[done:done+size])
+		done += j
+		if err != nil {
+			return d[:done], err
+		}
+		if progress != nil {
+			progress(done, n)
+		}
+	}
+	return d, nil
+}
+
+// CompareDevices reads each of the listed registers from the devices
+// connected to a and b, and returns the registers whose values differ
+// along with the value read from each device. This can help diagnose
+// why one of a pair of supposedly identical devices misbehaves.
+func CompareDevices(a, b *Conn, regs []byte) (map[byte][2]byte, error) {
+	diffs := make(map[byte][2]byte)
+	for _, reg := range regs {
+		var va, vb [1]byte
+		if _, err := a.ReadRegBuf(reg, va[:]); err != nil {
+			return diffs, fmt.Errorf("%s: register %02xh: %w", member(a), reg, err)
+		}
+		if _, err := b.ReadRegBuf(reg, vb[:]); err != nil {
+			return diffs, fmt.Errorf("%s: register %02xh: %w", member(b), reg, err)
+		}
+		if va != vb {
+			diffs[reg] = [2]byte{va[0], vb[0]}
+		}
+	}
+	return diffs, nil
+}
+
+// ReadStableRange reads the n byte value starting at register reg,
+// decoded in the connection's byte order, until it falls within the
+// inclusive range [lo, hi], making at most tries attempts. This
+// filters the transient spurious values some devices produce, for
+// example while starting up. The value is treated as two's complement
+// when lo is negative. If no value read is in range, the last one is
+// returned with an error wrapping ErrRange.
+func (c *Conn) ReadStableRange(reg byte, n int, lo, hi int64, tries int) (int64, error) {
+	if tries < 1 || lo > hi {
+		return 0, ErrInvalid
+	}
+	var v int64
+	for i := 0; i < tries; i++ {
+		u, err := c.readUint(reg, n)
+		if err != nil {
+			return 0, err
+		}
+		if lo < 0 {
+			v = signExtend(u, n)
+		} else {
+			v = int64(u)
+		}
+		if v >= lo && v <= hi {
+			return v, nil
+		}
+	}
+	return v, fmt.Errorf("register %02xh value %d not in [%d,%d] after %d tries: %w", reg, v, lo, hi, tries, ErrRange)
+}
+
+// ReadWhenReady polls register statusReg until any of the readyMask
+// bits are set, and then reads n bytes starting at register dataReg.
+// This is the data-ready handshake of many sensors, where reading the
+// data clears the ready indication. An error wrapping ErrTimeout is
+// returned if the device is not ready within timeout.
+func (c *Conn) ReadWhenReady(statusReg, readyMask, dataReg byte, n int, timeout time.Duration) ([]byte, error) {
+	if n < 1 || n > maxMsg {
+		return nil, ErrInvalid
+	}
+	deadline := now().Add(timeout)
+	var status [1]byte
+	for {
+		if _, err := c.ReadRegBuf(statusReg, status[:]); err != nil {
+			return nil, err
+		}
+		if status[0]&readyMask != 0 {
+			break
+		}
+		if now().After(deadline) {
+			return nil, fmt.Errorf("register %02xh status %02xh not ready after %v: %w", statusReg, status[0], timeout, ErrTimeout)
+		}
+		sleep(time.Millisecond)
+	}
+	d := make([]byte, n)
+	if _, err := c.ReadRegBuf(dataReg, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// WriteConfirm writes writeVal to register writeReg and then polls
+// register statusReg until its expectMask bits equal expectVal. This
+// suits devices that accept commands on one register and report
+// their outcome on another. An error wrapping ErrTimeout is returned
+// if the expected status is not seen within timeout.
+func (c *Conn) WriteConfirm(writeReg, writeVal, statusReg, expectMask, expectVal byte, timeout time.Duration) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	err := c.writeRegs(writeReg, []byte{writeVal})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	var status [1]byte
+	for {
+		if _, err := c.ReadRegBuf(statusReg, status[:]); err != nil {
+			return err
+		}
+		if status[0]&expectMask == expectVal&expectMask {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("register %02xh status %02xh not %02xh/%02xh after %v: %w", statusReg, status[0], expectVal, expectMask, timeout, ErrTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// ReadDiscardFirst reads n bytes starting at register reg twice and
+// returns the result of the second read. Some converters, ADCs in
+// particular, return a stale or meaningless first conversion after a
+// change of mode, and their datasheets direct that it be ignored.
+// The connection is held across both reads.
+func (c *Conn) ReadDiscardFirst(reg byte, n int) ([]byte, error) {
+	if n < 1 || n > maxMsg {
+		return nil, ErrInvalid
+	}
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	d := make([]byte, n)
+	if _, err := c.readReg(reg, d); err != nil {
+		return nil, err
+	}
+	if _, err := c.readReg(reg, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// WriteSettleVerify writes val to register reg, waits settle for the
+// device to apply it, and then reads the register back. Some
+// configuration registers trigger an internal recalibration, and only
+// read back the written value once it completes. An error wrapping
+// ErrVerify is returned if the value read differs from val.
+func (c *Conn) WriteSettleVerify(reg, val byte, settle time.Duration) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	err := c.writeRegs(reg, []byte{val})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	sleep(settle)
+	var d [1]byte
+	if _, err := c.ReadRegBuf(reg, d[:]); err != nil {
+		return err
+	}
+	if d[0] != val {
+		return fmt.Errorf("register %02xh reads %02xh, not %02xh, after %v: %w", reg, d[0], val, settle, ErrVerify)
+	}
+	return nil
+}
+
+// RegUint16 reads the 16-bit value starting at register reg, decoded
+// in the connection's byte order. The register pointer write and the
+// read are made without releasing the connection.
+func (c *Conn) RegUint16(reg byte) (uint16, error) {
+	v, err := c.readUint(reg, 2)
+	return uint16(v), err
+}
+
+// RegUint32 reads the 32-bit value starting at register reg, decoded
+// in the connection's byte order.
+func (c *Conn) RegUint32(reg byte) (uint32, error) {
+	v, err := c.readUint(reg, 4)
+	return uint32(v), err
+}
+
+// writeUint writes the n byte value v, encoded in the connection's
+// byte order, starting at register reg.
+func (c *Conn) writeUint(reg byte, n int, v uint64) error {
+	order, err := c.byteOrder()
+	if err != nil {
+		return err
+	}
+	var d [8]byte
+	encodeUint(order, d[:n], v)
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	return c.writeRegs(reg, d[:n])
+}
+
+// SetRegUint16 writes the 16-bit val, encoded in the connection's
+// byte order, starting at register reg, in a single write.
+func (c *Conn) SetRegUint16(reg byte, val uint16) error {
+	return c.writeUint(reg, 2, uint64(val))
+}
+
+// SetRegUint32 writes the 32-bit val, encoded in the connection's
+// byte order, starting at register reg, in a single write.
+func (c *Conn) SetRegUint32(reg byte, val uint32) error {
+	return c.writeUint(reg, 4, uint64(val))
+}
+
+// ReadRatio reads the n byte unsigned values starting at registers
+// numReg and denReg, decoded in the connection's byte order, and
+// returns the ratio of the first to the second. Ratiometric sensors
+// report their measurements this way. An error wrapping ErrRange is
+// returned if the denominator is zero.
+func (c *Conn) ReadRatio(numReg, denReg byte, n int) (float64, error) {
+	num, err := c.readUint(numReg, n)
+	if err != nil {
+		return 0, err
+	}
+	den, err := c.readUint(denReg, n)
+	if err != nil {
+		return 0, err
+	}
+	if den == 0 {
+		return 0, fmt.Errorf("denominator register %02xh is zero: %w", denReg, ErrRange)
+	}
+	return float64(num) / float64(den), nil
+}
+
+// SetReg writes val to register reg.
+func (c *Conn) SetReg(reg, val byte) error {
+	return c.SetRegs(reg, []byte{val})
+}
+
+// SetRegs writes vals to the device, starting at register reg, as a
+// single write transaction. Payloads too large for one transaction
+// are rejected rather than split. A short write returns a
+// *TruncationError counting the bytes written, including the
+// register address.
+func (c *Conn) SetRegs(reg byte, vals []byte) error {
+	if len(vals)+1 > maxMsg {
+		return fmt.Errorf("%d byte register write exceeds %d bytes: %w", len(vals)+1, maxMsg, ErrInvalid)
+	}
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	return c.writeRegs(reg, vals)
+}
+
+// UpdatePair reads registers regHi and regLo, which together hold one
+// setting, passes their values to fn, and writes the values fn
+// returns back to regHi and then regLo. The connection is held
+// throughout, so no other use of it can observe or cause an
+// inconsistent combination.
+func (c *Conn) UpdatePair(regHi, regLo byte, fn func(hi, lo byte) (byte, byte)) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	var hi, lo [1]byte
+	if _, err := c.readReg(regHi, hi[:]); err != nil {
+		return err
+	}
+	if _, err := c.readReg(regLo, lo[:]); err != nil {
+		return err
+	}
+	h, l := fn(hi[0], lo[0])
+	if err := c.writeRegs(regHi, []byte{h}); err != nil {
+		return err
+	}
+	return c.writeRegs(regLo, []byte{l})
+}
+
+// WaitRegBit polls register reg, every interval, until its mask
+// selected bits equal want. A zero interval polls as fast as
+// possible. Failed reads, for example while an EEPROM completes a
+// write cycle and does not acknowledge, are retried until timeout
+// expires. An error wrapping ErrTimeout, and reporting the last value
+// read or the last error, is returned if the bits are not seen
+// within timeout.
+func (c *Conn) WaitRegBit(reg, mask, want byte, interval, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var d [1]byte
+	for {
+		_, err := c.ReadRegBuf(reg, d[:])
+		if err == nil && d[0]&mask == want&mask {
+			return nil
+		}
+		if errors.Is(err, ErrClosed) || errors.Is(err, ErrInvalid) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("register %02xh unreadable after %v: %v: %w", reg, timeout, err, ErrTimeout)
+			}
+			return fmt.Errorf("register %02xh value %02xh not %02xh/%02xh after %v: %w", reg, d[0], want, mask, timeout, ErrTimeout)
+		}
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+	}
+}
+
+// detectSamples is the number of readings DetectByteOrder takes.
+const detectSamples = 4
+
+// DetectByteOrder infers the byte order of the 16-bit value starting
+// at register reg, an aid for bringing up a device with no known
+// reference value. Before each of several readings, monotonicSource
+// is called. It must cause the device's value to increase, by less
+// than 256, and return the expected size of the increase, for example
+// by stepping a DAC that feeds an ADC input. The byte order whose
+// decoding of the readings increases consistently, and closest to the
+// expected steps, is returned. An error wrapping ErrNoEndian is
+// returned if neither order, or both equally, fit the readings.
+func (c *Conn) DetectByteOrder(reg byte, monotonicSource func() uint16) (binary.ByteOrder, error) {
+	var raw [detectSamples][2]byte
+	var steps [detectSamples]int
+	for i := range raw {
+		steps[i] = int(monotonicSource())
+		if _, err := c.ReadRegBuf(reg, raw[i][:]); err != nil {
+			return nil, err
+		}
+	}
+	orders := []binary.ByteOrder{binary.BigEndian, binary.LittleEndian}
+	best, bestMiss := -1, 0
+	tie := false
+	for j, order := range orders {
+		miss := 0
+		consistent := true
+		for i := 1; i < detectSamples; i++ {
+			d := int(int16(order.Uint16(raw[i][:]) - order.Uint16(raw[i-1][:])))
+			if d <= 0 {
+				consistent = false
+				break
+			}
+			if d > steps[i] {
+				miss += d - steps[i]
+			} else {
+				miss += steps[i] - d
+			}
+		}
+		if !consistent {
+			continue
+		}
+		switch {
+		case best < 0 || miss < bestMiss:
+			best, bestMiss, tie = j, miss, false
+		case miss == bestMiss:
+			tie = true
+		}
+	}
+	if best < 0 {
+		return nil, fmt.Errorf("register %02xh value did not increase: %w", reg, ErrNoEndian)
+	}
+	if tie {
+		return nil, fmt.Errorf("register %02xh byte order is ambiguous: %w", reg, ErrNoEndian)
+	}
+	return orders[best], nil
+}
@@ -0,0 +1,506 @@
+package i2c
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWriteConfirm(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	d := a.devs[0x40]
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	// The device reports completion in status register 02h only
+	// once a command is written to register 01h.
+	d.regs[0x02] = 0x01
+	d.onWrite = func(data []byte) {
+		if len(data) == 2 && data[0] == 0x01 {
+			d.regs[0x02] = 0x81
+		}
+	}
+	if err := c.WriteConfirm(0x01, 0x10, 0x02, 0x80, 0x80, 10*time.Millisecond); err != nil {
+		t.Errorf("WriteConfirm failed: %v", err)
+	}
+	if got, want := a.ops(), []string{"w 40: 01 10", "w 40: 02", "r 40: 1"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if d.regs[0x01] != 0x10 {
+		t.Errorf("register 01h holds %#x, want 0x10", d.regs[0x01])
+	}
+	if err := c.WriteConfirm(0x01, 0x10, 0x02, 0x80, 0x00, 5*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("unconfirmed write got %v, want ErrTimeout", err)
+	}
+
+	a.ops()
+	d.nak = true
+	err := c.WriteConfirm(0x01, 0x10, 0x02, 0x80, 0x80, 10*time.Millisecond)
+	if !errors.Is(err, syscall.ENXIO) || errors.Is(err, ErrTimeout) {
+		t.Errorf("NACK'd write got %v, want ENXIO", err)
+	}
+	if ops := a.ops(); len(ops) != 1 {
+		t.Errorf("NACK'd write was followed by polling: %q", ops)
+	}
+}
+
+func TestWriteSettleVerify(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	d := a.devs[0x40]
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	clk := useClock(t)
+	// Register 05h takes 5ms to apply a written value.
+	const delay = 5 * time.Millisecond
+	var pending byte
+	var due time.Time
+	d.onWrite = func(data []byte) {
+		if len(data) == 2 && data[0] == 0x05 {
+			d.regs[0x05], pending, due = 0x00, data[1], clk.now().Add(delay)
+		}
+	}
+	d.onRead = func(buf []byte) {
+		if !due.IsZero() && !clk.now().Before(due) {
+			d.regs[0x05], due = pending, time.Time{}
+		}
+		copy(buf, d.regs[d.ptr:])
+	}
+
+	err := c.WriteSettleVerify(0x05, 0x33, 2*time.Millisecond)
+	if !errors.Is(err, ErrVerify) {
+		t.Errorf("short settle got %v, want ErrVerify", err)
+	}
+	if err := c.WriteSettleVerify(0x05, 0x33, delay); err != nil {
+		t.Errorf("WriteSettleVerify failed: %v", err)
+	}
+	if want := []time.Duration{2 * time.Millisecond, delay}; !reflect.DeepEqual(clk.sleeps, want) {
+		t.Errorf("slept %v, want %v", clk.sleeps, want)
+	}
+	d.onWrite, d.onRead = nil, nil
+
+	vs := []struct {
+		name string
+		set  func()
+	}{
+		{"write", func() { d.nak = true }},
+		{"read back", func() { d.nakAfter, d.written = 2, 0 }},
+	}
+	for _, v := range vs {
+		d.nak, d.nakAfter = false, 0
+		v.set()
+		err = c.WriteSettleVerify(0x05, 0x33, 0)
+		if !errors.Is(err, syscall.ENXIO) || errors.Is(err, ErrVerify) {
+			t.Errorf("NACK'd %s got %v, want ENXIO", v.name, err)
+		}
+	}
+}
+
+func TestReadFIFO(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	d := a.devs[0x40]
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	copy(d.regs[0x20:], []byte{1, 2, 3, 4, 5, 6})
+
+	recs, err := c.ReadFIFO(0x20, 2, 3)
+	if err != nil {
+		t.Fatalf("ReadFIFO failed: %v", err)
+	}
+	if want := [][]byte{{1, 2}, {3, 4}, {5, 6}}; !reflect.DeepEqual(recs, want) {
+		t.Errorf("got %v, want %v", recs, want)
+	}
+	if recs[0] = append(recs[0], 0xff); recs[1][0] != 3 {
+		t.Errorf("appending to a record overwrote the next: %v", recs)
+	}
+	if got, want := a.ops(), []string{"w 40: 20", "r 40: 6"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	recs, err = c.ReadFIFO(0x20, 2, 0)
+	if err != nil || recs != nil {
+		t.Errorf("zero count: got %v, %v, want no records", recs, err)
+	}
+	if ops := a.ops(); len(ops) != 0 {
+		t.Errorf("zero count accessed the bus: %q", ops)
+	}
+
+	d.short = 5
+	recs, err = c.ReadFIFO(0x20, 2, 3)
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("short read: got %v, want %v", err, ErrTruncated)
+	}
+	if want := [][]byte{{1, 2}, {3, 4}}; !reflect.DeepEqual(recs, want) {
+		t.Errorf("short read: got %v, want %v", recs, want)
+	}
+
+	d.short = 0
+	d.nak = true
+	if _, err := c.ReadFIFO(0x20, 2, 3); !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("absent device: got %v, want %v", err, syscall.ENXIO)
+	}
+	for _, v := range [][2]int{{0, 1}, {2, -1}, {maxMsg, 2}} {
+		if _, err := c.ReadFIFO(0x20, v[0], v[1]); !errors.Is(err, ErrInvalid) {
+			t.Errorf("ReadFIFO(20h, %d, %d): got %v, want %v", v[0], v[1], err, ErrInvalid)
+		}
+	}
+}
+
+func TestReadLarge(t *testing.T) {
+	a := newFakeAdapter(0x50)
+	d := a.devs[0x50]
+	c := newFakeConn(t, a, 0x50, binary.BigEndian)
+	next := byte(0)
+	d.onRead = func(buf []byte) {
+		for i := range buf {
+			buf[i] = next
+			next++
+		}
+	}
+
+	var calls [][2]int
+	progress := func(done, total int) { calls = append(calls, [2]int{done, total}) }
+	got, err := c.ReadLarge(0x01fe, 10, 4, progress)
+	if err != nil {
+		t.Fatalf("ReadLarge failed: %v", err)
+	}
+	if want := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}; !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+	want := []string{
+		"w 50: 01 fe", "r 50: 4",
+		"w 50: 02 02", "r 50: 4",
+		"w 50: 02 06", "r 50: 2",
+	}
+	if ops := a.ops(); !equalStrings(ops, want) {
+		t.Errorf("got %q, want %q", ops, want)
+	}
+	if want := [][2]int{{4, 10}, {8, 10}, {10, 10}}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("progress got %v, want %v", calls, want)
+	}
+
+	calls, next = nil, 0
+	d.short = 3
+	got, err = c.ReadLarge(0, 10, 4, progress)
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("short read: got %v, want %v", err, ErrTruncated)
+	}
+	if want := []byte{0, 1, 2}; !bytes.Equal(got, want) {
+		t.Errorf("short read: got % x, want % x", got, want)
+	}
+	if calls != nil {
+		t.Errorf("short read reported progress: %v", calls)
+	}
+
+	if _, err := c.ReadLarge(0xfff0, 17, 4, nil); !errors.Is(err, ErrInvalid) {
+		t.Errorf("read beyond 64k: got %v, want %v", err, ErrInvalid)
+	}
+	if _, err := c.ReadLarge(0, 4, 0, nil); !errors.Is(err, ErrInvalid) {
+		t.Errorf("zero chunk: got %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestCompareDevices(t *testing.T) {
+	a := newFakeAdapter(0x40, 0x41)
+	ca := newFakeConn(t, a, 0x40, binary.BigEndian)
+	cb := newFakeConn(t, a, 0x41, binary.BigEndian)
+	for reg := byte(0); reg < 8; reg++ {
+		a.devs[0x40].regs[reg] = 0x10 + reg
+		a.devs[0x41].regs[reg] = 0x10 + reg
+	}
+	a.devs[0x41].regs[0x02] = 0xa2
+	a.devs[0x41].regs[0x05] = 0xa5
+
+	diffs, err := CompareDevices(ca, cb, []byte{0, 1, 2, 3, 4, 5, 6, 7})
+	if err != nil {
+		t.Fatalf("CompareDevices failed: %v", err)
+	}
+	want := map[byte][2]byte{0x02: {0x12, 0xa2}, 0x05: {0x15, 0xa5}}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("got %v, want %v", diffs, want)
+	}
+
+	a.devs[0x41].nak = true
+	if _, err := CompareDevices(ca, cb, []byte{0}); !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("absent device: got %v, want %v", err, syscall.ENXIO)
+	}
+}
+
+// feed makes d's reads return the listed values in turn, repeating
+// the last, and returns a count of the reads.
+func feed(d *fakeDev, vals ...[]byte) *int {
+	n := new(int)
+	d.onRead = func(buf []byte) {
+		v := vals[len(vals)-1]
+		if *n < len(vals) {
+			v = vals[*n]
+		}
+		copy(buf, v)
+		*n++
+	}
+	return n
+}
+
+func TestReadStableRange(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	d := a.devs[0x40]
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+
+	reads := feed(d, []byte{0xff, 0xff}, []byte{0x00, 0x64})
+	v, err := c.ReadStableRange(0x10, 2, 0, 1000, 3)
+	if err != nil || v != 100 {
+		t.Errorf("got %d, %v, want 100", v, err)
+	}
+	if *reads != 2 {
+		t.Errorf("read %d times, want 2", *reads)
+	}
+
+	reads = feed(d, []byte{0xff, 0xce})
+	v, err = c.ReadStableRange(0x10, 2, -100, 100, 3)
+	if err != nil || v != -50 {
+		t.Errorf("signed: got %d, %v, want -50", v, err)
+	}
+	if *reads != 1 {
+		t.Errorf("signed: read %d times, want 1", *reads)
+	}
+
+	reads = feed(d, []byte{0x7f, 0xff})
+	v, err = c.ReadStableRange(0x10, 2, -100, 100, 3)
+	if !errors.Is(err, ErrRange) || v != 32767 {
+		t.Errorf("out of range: got %d, %v, want 32767, %v", v, err, ErrRange)
+	}
+	if *reads != 3 {
+		t.Errorf("out of range: read %d times, want 3", *reads)
+	}
+
+	if _, err := c.ReadStableRange(0x10, 2, 0, 100, 0); !errors.Is(err, ErrInvalid) {
+		t.Errorf("zero tries: got %v, want %v", err, ErrInvalid)
+	}
+	if _, err := c.ReadStableRange(0x10, 2, 100, 0, 3); !errors.Is(err, ErrInvalid) {
+		t.Errorf("empty range: got %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestReadWhenReady(t *testing.T) {
+	clk := useClock(t)
+	a := newFakeAdapter(0x76)
+	d := a.devs[0x76]
+	c := newFakeConn(t, a, 0x76, binary.BigEndian)
+	copy(d.regs[0x20:], []byte{0x12, 0x34, 0x56})
+	polls := 0
+	d.onRead = func(buf []byte) {
+		if d.ptr != 0x00 {
+			copy(buf, d.regs[d.ptr:])
+			return
+		}
+		if polls++; polls >= 3 {
+			buf[0] = 0x08
+		} else {
+			buf[0] = 0x01
+		}
+	}
+
+	got, err := c.ReadWhenReady(0x00, 0x08, 0x20, 3, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("ReadWhenReady failed: %v", err)
+	}
+	if want := []byte{0x12, 0x34, 0x56}; !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+	if polls != 3 {
+		t.Errorf("status polled %d times, want 3", polls)
+	}
+	want := []string{"w 76: 00", "r 76: 1", "w 76: 00", "r 76: 1", "w 76: 00", "r 76: 1", "w 76: 20", "r 76: 3"}
+	if ops := a.ops(); !equalStrings(ops, want) {
+		t.Errorf("got %q, want %q", ops, want)
+	}
+
+	polls, clk.sleeps = -100, nil
+	if _, err := c.ReadWhenReady(0x00, 0x08, 0x20, 3, 5*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("never ready: got %v, want %v", err, ErrTimeout)
+	}
+	if len(clk.sleeps) != 6 {
+		t.Errorf("never ready: slept %v, want 6 polling intervals", clk.sleeps)
+	}
+	for _, op := range a.ops() {
+		if op == "w 76: 20" {
+			t.Error("data read although never ready")
+		}
+	}
+
+	if _, err := c.ReadWhenReady(0x00, 0x08, 0x20, 0, time.Millisecond); !errors.Is(err, ErrInvalid) {
+		t.Errorf("zero length: got %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestReadDiscardFirst(t *testing.T) {
+	a := newFakeAdapter(0x48)
+	d := a.devs[0x48]
+	c := newFakeConn(t, a, 0x48, binary.BigEndian)
+	reads := feed(d, []byte{0xde, 0xad}, []byte{0x01, 0x23})
+
+	got, err := c.ReadDiscardFirst(0x00, 2)
+	if err != nil {
+		t.Fatalf("ReadDiscardFirst failed: %v", err)
+	}
+	if want := []byte{0x01, 0x23}; !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+	if *reads != 2 {
+		t.Errorf("device read %d times, want 2", *reads)
+	}
+	if got, want := a.ops(), []string{"w 48: 00", "r 48: 2", "w 48: 00", "r 48: 2"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	d.nak = true
+	if _, err := c.ReadDiscardFirst(0x00, 2); !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("absent device: got %v, want %v", err, syscall.ENXIO)
+	}
+	if _, err := c.ReadDiscardFirst(0x00, 0); !errors.Is(err, ErrInvalid) {
+		t.Errorf("zero length: got %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestReadRatio(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	d := a.devs[0x40]
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	copy(d.regs[0x10:], []byte{0x01, 0x00, 0x04, 0x00})
+
+	r, err := c.ReadRatio(0x10, 0x12, 2)
+	if err != nil || r != 0.25 {
+		t.Errorf("got %v, %v, want 0.25", r, err)
+	}
+	if got, want := a.ops(), []string{"w 40: 10", "r 40: 2", "w 40: 12", "r 40: 2"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	c.endian = binary.LittleEndian
+	if r, err := c.ReadRatio(0x10, 0x12, 2); err != nil || r != 0.25 {
+		t.Errorf("little endian: got %v, %v, want 0.25", r, err)
+	}
+	if r, err := c.ReadRatio(0x12, 0x10, 1); err != nil || r != 4 {
+		t.Errorf("one byte: got %v, %v, want 4", r, err)
+	}
+
+	if _, err := c.ReadRatio(0x10, 0x11, 1); !errors.Is(err, ErrRange) {
+		t.Errorf("zero denominator: got %v, want %v", err, ErrRange)
+	}
+	d.nak = true
+	if _, err := c.ReadRatio(0x10, 0x12, 2); !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("absent device: got %v, want %v", err, syscall.ENXIO)
+	}
+}
+
+func TestUpdatePair(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	d := a.devs[0x40]
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	d.regs[0x04], d.regs[0x05] = 0x12, 0xff
+
+	// Increment the 16-bit value the pair holds.
+	inc := func(hi, lo byte) (byte, byte) {
+		v := uint16(hi)<<8 | uint16(lo) + 1
+		return byte(v >> 8), byte(v)
+	}
+	if err := c.UpdatePair(0x04, 0x05, inc); err != nil {
+		t.Fatalf("UpdatePair failed: %v", err)
+	}
+	if d.regs[0x04] != 0x13 || d.regs[0x05] != 0x00 {
+		t.Errorf("got %02x %02x, want 13 00", d.regs[0x04], d.regs[0x05])
+	}
+	want := []string{"w 40: 04", "r 40: 1", "w 40: 05", "r 40: 1", "w 40: 04 13", "w 40: 05 00"}
+	if got := a.ops(); !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	d.nak = true
+	called := false
+	err := c.UpdatePair(0x04, 0x05, func(hi, lo byte) (byte, byte) {
+		called = true
+		return hi, lo
+	})
+	if !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("absent device: got %v, want %v", err, syscall.ENXIO)
+	}
+	if called {
+		t.Error("fn called although the registers were not read")
+	}
+}
+
+func TestDetectByteOrder(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		a := newFakeAdapter(0x48)
+		d := a.devs[0x48]
+		c := newFakeConn(t, a, 0x48, binary.BigEndian)
+		// Steps of 5 from 12f0h carry into the high byte, which
+		// only the device's own byte order decodes as increasing.
+		v := uint16(0x12f0)
+		step := func() uint16 {
+			v += 5
+			order.PutUint16(d.regs[0x00:], v)
+			return 5
+		}
+		got, err := c.DetectByteOrder(0x00, step)
+		if err != nil {
+			t.Errorf("%v: DetectByteOrder failed: %v", order, err)
+		} else if got != order {
+			t.Errorf("got %v, want %v", got, order)
+		}
+		if ops := a.ops(); len(ops) != 2*detectSamples {
+			t.Errorf("%v: got %q, want %d reads", order, ops, detectSamples)
+		}
+	}
+
+	a := newFakeAdapter(0x48)
+	d := a.devs[0x48]
+	c := newFakeConn(t, a, 0x48, binary.BigEndian)
+	d.regs[0x00], d.regs[0x01] = 0x12, 0x34
+	if _, err := c.DetectByteOrder(0x00, func() uint16 { return 5 }); !errors.Is(err, ErrNoEndian) {
+		t.Errorf("unchanging value: got %v, want %v", err, ErrNoEndian)
+	}
+	d.nak = true
+	if _, err := c.DetectByteOrder(0x00, func() uint16 { return 5 }); !errors.Is(err, syscall.ENXIO) {
+		t.Errorf("absent device: got %v, want %v", err, syscall.ENXIO)
+	}
+}
+
+func TestReadBiased(t *testing.T) {
+	a := newFakeAdapter(0x4c)
+	d := a.devs[0x4c]
+	c := newFakeConn(t, a, 0x4c, binary.BigEndian)
+	d.regs[0x00] = 0x20
+	copy(d.regs[0x10:], []byte{0x01, 0x00, 0, 0, 0, 0, 0, 0x40})
+	copy(d.regs[0x20:], []byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	vs := []struct {
+		reg  byte
+		n    int
+		bias int64
+		want int64
+	}{
+		{0x00, 1, 64, -32},
+		{0x00, 1, 0, 32},
+		{0x10, 2, 0x0200, -0x0100},
+		{0x10, 8, 0x0100000000000041, -1},
+		{0x20, 8, 1<<63 - 1, 0},
+	}
+	for _, v := range vs {
+		got, err := c.ReadBiased(v.reg, v.n, v.bias)
+		if err != nil || got != v.want {
+			t.Errorf("ReadBiased(%02xh, %d, %d) = %d, %v, want %d", v.reg, v.n, v.bias, got, err, v.want)
+		}
+	}
+	c.endian = binary.LittleEndian
+	if got, err := c.ReadBiased(0x10, 2, 0x0200); err != nil || got != -0x01ff {
+		t.Errorf("little endian: got %d, %v, want %d", got, err, -0x01ff)
+	}
+	for _, n := range []int{0, 9} {
+		if _, err := c.ReadBiased(0x00, n, 0); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%d bytes: got %v, want %v", n, err, ErrInvalid)
+		}
+	}
+	if ops := a.ops(); len(ops) != 2*(len(vs)+1) {
+		t.Errorf("invalid sizes accessed the bus: %q", ops)
+	}
+}
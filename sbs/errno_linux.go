@@ -0,0 +1,7 @@
+package sbs
+
+import "syscall"
+
+// nakErrnos are the errors adapters report for a command the battery
+// does not acknowledge.
+var nakErrnos = []syscall.Errno{syscall.ENXIO, syscall.EREMOTEIO, syscall.EIO}
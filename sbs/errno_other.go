@@ -0,0 +1,9 @@
+//go:build !linux
+
+package sbs
+
+import "syscall"
+
+// nakErrnos are the errors adapters report for a command the battery
+// does not acknowledge. EREMOTEIO is specific to Linux.
+var nakErrnos = []syscall.Errno{syscall.ENXIO, syscall.EIO}
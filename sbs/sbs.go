@@ -0,0 +1,405 @@
+// Package sbs reads Smart Battery System battery packs over SMBus.
+//
+// The command set and scaling follow the Smart Battery Data
+// Specification, revision 1.1:
+//
+//	http://sbs-forum.org/specs/sbdat110.pdf
+package sbs // zappem.net/pub/io/i2c/sbs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+
+	"zappem.net/pub/io/i2c"
+)
+
+// Addr is the SMBus address of a smart battery.
+const Addr = 0x0b
+
+// ManufacturerAccess etc are the SBS command codes.
+const (
+	ManufacturerAccess     = 0x00
+	RemainingCapacityAlarm = 0x01
+	RemainingTimeAlarm     = 0x02
+	BatteryModeCmd         = 0x03
+	AtRate                 = 0x04
+	Temperature            = 0x08
+	Voltage                = 0x09
+	Current                = 0x0a
+	AverageCurrent         = 0x0b
+	MaxError               = 0x0c
+	RelativeStateOfCharge  = 0x0d
+	AbsoluteStateOfCharge  = 0x0e
+	RemainingCapacity      = 0x0f
+	FullChargeCapacity     = 0x10
+	RunTimeToEmpty         = 0x11
+	AverageTimeToEmpty     = 0x12
+	AverageTimeToFull      = 0x13
+	ChargingCurrent        = 0x14
+	ChargingVoltage        = 0x15
+	BatteryStatusCmd       = 0x16
+	CycleCount             = 0x17
+	DesignCapacity         = 0x18
+	DesignVoltage          = 0x19
+	SpecificationInfo      = 0x1a
+	ManufactureDate        = 0x1b
+	SerialNumber           = 0x1c
+	ManufacturerName       = 0x20
+	DeviceName             = 0x21
+	DeviceChemistry        = 0x22
+	ManufacturerData       = 0x23
+)
+
+// ErrUnsupported indicates the battery declined (NAK'd) a command.
+// Many of the SBS commands are optional.
+var ErrUnsupported = errors.New("command not supported by battery")
+
+// ErrUnavailable indicates the battery reported a value as not
+// applicable, for example a time to empty while charging.
+var ErrUnavailable = errors.New("value not available")
+
+// Device is the SMBus access needed to talk to a smart battery. It is
+// satisfied by an *i2c.Conn using binary.LittleEndian, the byte order
+// SBS uses for its words.
+type Device interface {
+	SMBusReadWordData(cmd byte) (uint16, error)
+	SMBusReadBlock(cmd byte) ([]byte, error)
+}
+
+// Battery holds a connection to a smart battery.
+type Battery struct {
+	d Device
+}
+
+// New returns a Battery that uses d to talk to the battery.
+func New(d Device) *Battery {
+	return &Battery{d: d}
+}
+
+// Open connects to the smart battery on the named bus device file.
+// If the adapter supports FUNC_SMBUS_PEC, packet error checking is
+// enabled, as smart batteries implement it, so that corrupted values
+// are reported as errors wrapping i2c.ErrPEC rather than returned.
+// The caller should Close the returned connection when done.
+func Open(bus string) (*Battery, *i2c.Conn, error) {
+	c, err := i2c.NewConn(bus, Addr, false, binary.LittleEndian)
+	if err != nil {
+		return nil, nil, err
+	}
+	if funcs, err := c.Funcs(); err == nil && funcs&i2c.FUNC_SMBUS_PEC != 0 {
+		if err := c.SetPEC(true); err != nil {
+			c.Close()
+			return nil, nil, err
+		}
+	}
+	return New(c), c, nil
+}
+
+// nak maps a declined transaction error to ErrUnsupported.
+func nak(cmd byte, err error) error {
+	var eno syscall.Errno
+	if errors.As(err, &eno) {
+		for _, x := range nakErrnos {
+			if eno == x {
+				return fmt.Errorf("command %02xh: %w: %v", cmd, ErrUnsupported, err)
+			}
+		}
+	}
+	return err
+}
+
+// Word reads the raw word value of an SBS command.
+func (b *Battery) Word(cmd byte) (uint16, error) {
+	v, err := b.d.SMBusReadWordData(cmd)
+	if err != nil {
+		return 0, nak(cmd, err)
+	}
+	return v, nil
+}
+
+// Text reads the string value of an SBS block command.
+func (b *Battery) Text(cmd byte) (string, error) {
+	d, err := b.d.SMBusReadBlock(cmd)
+	if err != nil {
+		return "", nak(cmd, err)
+	}
+	return strings.TrimRight(string(d), "\x00 "), nil
+}
+
+// Voltage returns the pack voltage in mV.
+func (b *Battery) Voltage() (int, error) {
+	v, err := b.Word(Voltage)
+	return int(v), err
+}
+
+// Current returns the pack current in mA. Positive values indicate
+// the pack is charging.
+func (b *Battery) Current() (int, error) {
+	v, err := b.Word(Current)
+	return int(int16(v)), err
+}
+
+// AverageCurrent returns the one minute rolling average current in
+// mA.
+func (b *Battery) AverageCurrent() (int, error) {
+	v, err := b.Word(AverageCurrent)
+	return int(int16(v)), err
+}
+
+// Temperature returns the pack temperature in degrees Celsius. The
+// battery reports it in units of 0.1 K.
+func (b *Battery) Temperature() (float64, error) {
+	v, err := b.Word(Temperature)
+	if err != nil {
+		return 0, err
+	}
+	return KelvinTenthsToCelsius(v), nil
+}
+
+// KelvinTenthsToCelsius converts a temperature in units of 0.1 K to
+// degrees Celsius.
+func KelvinTenthsToCelsius(v uint16) float64 {
+	return float64(v)/10 - 273.15
+}
+
+// RelativeStateOfCharge returns the remaining capacity as a
+// percentage of the full charge capacity.
+func (b *Battery) RelativeStateOfCharge() (int, error) {
+	v, err := b.Word(RelativeStateOfCharge)
+	return int(v), err
+}
+
+// AbsoluteStateOfCharge returns the remaining capacity as a
+// percentage of the design capacity. It can exceed 100.
+func (b *Battery) AbsoluteStateOfCharge() (int, error) {
+	v, err := b.Word(AbsoluteStateOfCharge)
+	return int(v), err
+}
+
+// duration reads a minutes valued command. The value 65535 indicates
+// the battery is not in a state where the value applies.
+func (b *Battery) duration(cmd byte) (time.Duration, error) {
+	v, err := b.Word(cmd)
+	if err != nil {
+		return 0, err
+	}
+	if v == 0xffff {
+		return 0, ErrUnavailable
+	}
+	return time.Duration(v) * time.Minute, nil
+}
+
+// RunTimeToEmpty returns the predicted remaining run time at the
+// present rate of discharge.
+func (b *Battery) RunTimeToEmpty() (time.Duration, error) {
+	return b.duration(RunTimeToEmpty)
+}
+
+// AverageTimeToEmpty returns the predicted remaining run time at the
+// one minute average rate of discharge.
+func (b *Battery) AverageTimeToEmpty() (time.Duration, error) {
+	return b.duration(AverageTimeToEmpty)
+}
+
+// AverageTimeToFull returns the predicted time until the pack is
+// fully charged.
+func (b *Battery) AverageTimeToFull() (time.Duration, error) {
+	return b.duration(AverageTimeToFull)
+}
+
+// capacity reads a capacity valued command, scaling it according to
+// the battery's capacity mode. The returned unit is "mAh" or "mWh".
+func (b *Battery) capacity(cmd byte) (int, string, error) {
+	m, err := b.Mode()
+	if err != nil {
+		return 0, "", err
+	}
+	v, err := b.Word(cmd)
+	if err != nil {
+		return 0, "", err
+	}
+	if m&CapacityMode != 0 {
+		return 10 * int(v), "mWh", nil
+	}
+	return int(v), "mAh", nil
+}
+
+// RemainingCapacity returns the predicted remaining capacity and its
+// unit.
+func (b *Battery) RemainingCapacity() (int, string, error) {
+	return b.capacity(RemainingCapacity)
+}
+
+// FullChargeCapacity returns the predicted capacity when fully
+// charged and its unit.
+func (b *Battery) FullChargeCapacity() (int, string, error) {
+	return b.capacity(FullChargeCapacity)
+}
+
+// DesignCapacity returns the theoretical capacity of a new pack and
+// its unit.
+func (b *Battery) DesignCapacity() (int, string, error) {
+	return b.capacity(DesignCapacity)
+}
+
+// CycleCount returns the number of charge/discharge cycles the pack
+// has experienced.
+func (b *Battery) CycleCount() (int, error) {
+	v, err := b.Word(CycleCount)
+	return int(v), err
+}
+
+// SerialNumber returns the pack's serial number.
+func (b *Battery) SerialNumber() (uint16, error) {
+	return b.Word(SerialNumber)
+}
+
+// ManufactureDate returns the date the pack was manufactured.
+func (b *Battery) ManufactureDate() (time.Time, error) {
+	v, err := b.Word(ManufactureDate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(1980+int(v>>9), time.Month((v>>5)&0xf), int(v&0x1f), 0, 0, 0, 0, time.UTC), nil
+}
+
+// ManufacturerName returns the name of the pack's manufacturer.
+func (b *Battery) ManufacturerName() (string, error) {
+	return b.Text(ManufacturerName)
+}
+
+// DeviceName returns the pack's name.
+func (b *Battery) DeviceName() (string, error) {
+	return b.Text(DeviceName)
+}
+
+// DeviceChemistry returns the pack's chemistry, for example "LION".
+func (b *Battery) DeviceChemistry() (string, error) {
+	return b.Text(DeviceChemistry)
+}
+
+// BatteryMode holds the BatteryMode register bits.
+type BatteryMode uint16
+
+// InternalChargeController etc are the BatteryMode bits.
+const (
+	InternalChargeController BatteryMode = 1 << 0
+	PrimaryBatterySupport    BatteryMode = 1 << 1
+	ConditionFlag            BatteryMode = 1 << 7
+	ChargeControllerEnabled  BatteryMode = 1 << 8
+	PrimaryBattery           BatteryMode = 1 << 9
+	AlarmMode                BatteryMode = 1 << 13
+	ChargerMode              BatteryMode = 1 << 14
+	CapacityMode             BatteryMode = 1 << 15
+)
+
+// modeNames holds the names of the BatteryMode bits.
+var modeNames = []struct {
+	bit  BatteryMode
+	name string
+}{
+	{InternalChargeController, "INTERNAL_CHARGE_CONTROLLER"},
+	{PrimaryBatterySupport, "PRIMARY_BATTERY_SUPPORT"},
+	{ConditionFlag, "CONDITION_FLAG"},
+	{ChargeControllerEnabled, "CHARGE_CONTROLLER_ENABLED"},
+	{PrimaryBattery, "PRIMARY_BATTERY"},
+	{AlarmMode, "ALARM_MODE"},
+	{ChargerMode, "CHARGER_MODE"},
+	{CapacityMode, "CAPACITY_MODE"},
+}
+
+// String lists the names of the set BatteryMode bits.
+func (m BatteryMode) String() string {
+	var s []string
+	for _, n := range modeNames {
+		if m&n.bit != 0 {
+			s = append(s, n.name)
+		}
+	}
+	return strings.Join(s, "|")
+}
+
+// Mode reads the BatteryMode register.
+func (b *Battery) Mode() (BatteryMode, error) {
+	v, err := b.Word(BatteryModeCmd)
+	return BatteryMode(v), err
+}
+
+// BatteryStatus holds the BatteryStatus register bits.
+type BatteryStatus uint16
+
+// OverChargedAlarm etc are the BatteryStatus bits.
+const (
+	OverChargedAlarm         BatteryStatus = 1 << 15
+	TerminateChargeAlarm     BatteryStatus = 1 << 14
+	OverTempAlarm            BatteryStatus = 1 << 12
+	TerminateDischargeAlarm  BatteryStatus = 1 << 11
+	RemainingCapacityAlarmed BatteryStatus = 1 << 9
+	RemainingTimeAlarmed     BatteryStatus = 1 << 8
+	Initialized              BatteryStatus = 1 << 7
+	Discharging              BatteryStatus = 1 << 6
+	FullyCharged             BatteryStatus = 1 << 5
+	FullyDischarged          BatteryStatus = 1 << 4
+)
+
+// statusNames holds the names of the BatteryStatus bits.
+var statusNames = []struct {
+	bit  BatteryStatus
+	name string
+}{
+	{OverChargedAlarm, "OVER_CHARGED_ALARM"},
+	{TerminateChargeAlarm, "TERMINATE_CHARGE_ALARM"},
+	{OverTempAlarm, "OVER_TEMP_ALARM"},
+	{TerminateDischargeAlarm, "TERMINATE_DISCHARGE_ALARM"},
+	{RemainingCapacityAlarmed, "REMAINING_CAPACITY_ALARM"},
+	{RemainingTimeAlarmed, "REMAINING_TIME_ALARM"},
+	{Initialized, "INITIALIZED"},
+	{Discharging, "DISCHARGING"},
+	{FullyCharged, "FULLY_CHARGED"},
+	{FullyDischarged, "FULLY_DISCHARGED"},
+}
+
+// errorCodes holds the names of the BatteryStatus error codes.
+var errorCodes = []string{
+	"OK",
+	"Busy",
+	"ReservedCommand",
+	"UnsupportedCommand",
+	"AccessDenied",
+	"OverUnderflow",
+	"BadSize",
+	"UnknownError",
+}
+
+// ErrorCode returns the error code of the last command the battery
+// processed, and its name.
+func (s BatteryStatus) ErrorCode() (int, string) {
+	code := int(s & 0xf)
+	if code < len(errorCodes) {
+		return code, errorCodes[code]
+	}
+	return code, fmt.Sprintf("Code%d", code)
+}
+
+// String lists the names of the set BatteryStatus bits and the error
+// code.
+func (s BatteryStatus) String() string {
+	var names []string
+	for _, n := range statusNames {
+		if s&n.bit != 0 {
+			names = append(names, n.name)
+		}
+	}
+	_, code := s.ErrorCode()
+	return strings.Join(append(names, code), "|")
+}
+
+// Status reads the BatteryStatus register.
+func (b *Battery) Status() (BatteryStatus, error) {
+	v, err := b.Word(BatteryStatusCmd)
+	return BatteryStatus(v), err
+}
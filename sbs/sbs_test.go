@@ -0,0 +1,190 @@
+package sbs
+
+import (
+	"errors"
+	"math"
+	"syscall"
+	"testing"
+	"time"
+
+	"zappem.net/pub/io/i2c"
+)
+
+// fakePack simulates a smart battery. Commands it has no value for
+// are not acknowledged.
+type fakePack struct {
+	words  map[byte]uint16
+	blocks map[byte]string
+	// err, if set, is returned for all commands.
+	err error
+}
+
+func (p *fakePack) SMBusReadWordData(cmd byte) (uint16, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	v, ok := p.words[cmd]
+	if !ok {
+		return 0, syscall.ENXIO
+	}
+	return v, nil
+}
+
+func (p *fakePack) SMBusReadBlock(cmd byte) ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	v, ok := p.blocks[cmd]
+	if !ok {
+		return nil, syscall.ENXIO
+	}
+	return []byte(v), nil
+}
+
+func newPack() *fakePack {
+	return &fakePack{
+		words: map[byte]uint16{
+			BatteryModeCmd:        0x6001,
+			Temperature:           2982,
+			Voltage:               12345,
+			Current:               0xff38,
+			AverageCurrent:        0xff6a,
+			RelativeStateOfCharge: 87,
+			AbsoluteStateOfCharge: 104,
+			RemainingCapacity:     4200,
+			FullChargeCapacity:    4800,
+			RunTimeToEmpty:        0xffff,
+			AverageTimeToEmpty:    125,
+			AverageTimeToFull:     90,
+			BatteryStatusCmd:      0x00e2,
+			CycleCount:            31,
+			DesignCapacity:        5000,
+			ManufactureDate:       (2023-1980)<<9 | 6<<5 | 15,
+			SerialNumber:          0x1234,
+		},
+		blocks: map[byte]string{
+			ManufacturerName: "ACME",
+			DeviceName:       "PACK-4S1P\x00\x00",
+			DeviceChemistry:  "LION",
+		},
+	}
+}
+
+func TestBattery(t *testing.T) {
+	b := New(newPack())
+	ints := []struct {
+		name string
+		fn   func() (int, error)
+		want int
+	}{
+		{"Voltage", b.Voltage, 12345},
+		{"Current", b.Current, -200},
+		{"AverageCurrent", b.AverageCurrent, -150},
+		{"RelativeStateOfCharge", b.RelativeStateOfCharge, 87},
+		{"AbsoluteStateOfCharge", b.AbsoluteStateOfCharge, 104},
+		{"CycleCount", b.CycleCount, 31},
+	}
+	for _, v := range ints {
+		if got, err := v.fn(); err != nil || got != v.want {
+			t.Errorf("%s got %d, %v, want %d", v.name, got, err, v.want)
+		}
+	}
+	if got, err := b.Temperature(); err != nil || math.Abs(got-25.05) > 1e-9 {
+		t.Errorf("Temperature got %v, %v, want 25.05", got, err)
+	}
+	durations := []struct {
+		name string
+		fn   func() (time.Duration, error)
+		want time.Duration
+		err  error
+	}{
+		{"RunTimeToEmpty", b.RunTimeToEmpty, 0, ErrUnavailable},
+		{"AverageTimeToEmpty", b.AverageTimeToEmpty, 125 * time.Minute, nil},
+		{"AverageTimeToFull", b.AverageTimeToFull, 90 * time.Minute, nil},
+	}
+	for _, v := range durations {
+		if got, err := v.fn(); err != v.err || got != v.want {
+			t.Errorf("%s got %v, %v, want %v, %v", v.name, got, err, v.want, v.err)
+		}
+	}
+	texts := []struct {
+		name string
+		fn   func() (string, error)
+		want string
+	}{
+		{"ManufacturerName", b.ManufacturerName, "ACME"},
+		{"DeviceName", b.DeviceName, "PACK-4S1P"},
+		{"DeviceChemistry", b.DeviceChemistry, "LION"},
+	}
+	for _, v := range texts {
+		if got, err := v.fn(); err != nil || got != v.want {
+			t.Errorf("%s got %q, %v, want %q", v.name, got, err, v.want)
+		}
+	}
+	if got, err := b.SerialNumber(); err != nil || got != 0x1234 {
+		t.Errorf("SerialNumber got %#x, %v", got, err)
+	}
+	want := time.Date(2023, time.June, 15, 0, 0, 0, 0, time.UTC)
+	if got, err := b.ManufactureDate(); err != nil || !got.Equal(want) {
+		t.Errorf("ManufactureDate got %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	p := newPack()
+	b := New(p)
+	if v, unit, err := b.RemainingCapacity(); err != nil || v != 4200 || unit != "mAh" {
+		t.Errorf("RemainingCapacity got %d %s, %v, want 4200 mAh", v, unit, err)
+	}
+	p.words[BatteryModeCmd] |= uint16(CapacityMode)
+	if v, unit, err := b.DesignCapacity(); err != nil || v != 50000 || unit != "mWh" {
+		t.Errorf("DesignCapacity got %d %s, %v, want 50000 mWh", v, unit, err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	p := newPack()
+	b := New(p)
+	s, err := b.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if got, want := s.String(), "INITIALIZED|DISCHARGING|FULLY_CHARGED|ReservedCommand"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if code, name := BatteryStatus(0x4809).ErrorCode(); code != 9 || name != "Code9" {
+		t.Errorf("got %d %q, want 9 \"Code9\"", code, name)
+	}
+	if got, want := (OverChargedAlarm | OverTempAlarm | 3).String(), "OVER_CHARGED_ALARM|OVER_TEMP_ALARM|UnsupportedCommand"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	m, err := b.Mode()
+	if err != nil {
+		t.Fatalf("Mode failed: %v", err)
+	}
+	if got, want := m.String(), "INTERNAL_CHARGE_CONTROLLER|ALARM_MODE|CHARGER_MODE"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestOptionalCommands(t *testing.T) {
+	p := newPack()
+	delete(p.words, CycleCount)
+	delete(p.blocks, DeviceChemistry)
+	b := New(p)
+	if _, err := b.CycleCount(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("CycleCount got %v, want ErrUnsupported", err)
+	}
+	if _, err := b.DeviceChemistry(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("DeviceChemistry got %v, want ErrUnsupported", err)
+	}
+	delete(p.words, BatteryModeCmd)
+	if _, _, err := b.FullChargeCapacity(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("FullChargeCapacity without a mode got %v, want ErrUnsupported", err)
+	}
+
+	p.err = i2c.ErrPEC
+	if _, err := b.Voltage(); err != i2c.ErrPEC {
+		t.Errorf("PEC failure got %v, want ErrPEC", err)
+	}
+}
@@ -0,0 +1,319 @@
+package i2c
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+)
+
+// ProbeMethod selects how Scan tests for the presence of a device.
+type ProbeMethod int
+
+// ProbeAuto etc are the supported probe methods. ProbeAuto follows
+// the i2cdetect convention: a receive byte for the 0x30-0x37 and
+// 0x50-0x5f ranges, where write-protectable EEPROMs and some RTCs
+// live, and a quick write everywhere else, including all 10-bit
+// addresses.
+const (
+	ProbeAuto ProbeMethod = iota
+	ProbeQuick
+	ProbeRead
+	ProbeSkip
+)
+
+// String returns a short name for the probe method.
+func (m ProbeMethod) String() string {
+	switch m {
+	case ProbeAuto:
+		return "auto"
+	case ProbeQuick:
+		return "quick"
+	case ProbeRead:
+		return "read"
+	case ProbeSkip:
+		return "skip"
+	}
+	return "invalid"
+}
+
+// ProbeRange overrides the probe method for an inclusive range of
+// addresses.
+type ProbeRange struct {
+	First, Last uint
+	Method      ProbeMethod
+}
+
+// ScanPolicy controls which addresses Scan probes, and how. Some
+// devices are disturbed by quick writes, others by reads, so the
+// default convention is not right for every board.
+type ScanPolicy struct {
+	// First and Last bound the scanned addresses. When both are
+	// zero, 0x08 through 0x77 are scanned, which skips the
+	// addresses reserved by the i2c specification, or, for 10-bit
+	// scans, 0x000 through 0x3ff.
+	First, Last uint
+
+	// TenBit scans 10-bit addresses.
+	TenBit bool
+
+	// Method, unless ProbeAuto, is used for all addresses. This
+	// mirrors the -q and -r options of i2cdetect.
+	Method ProbeMethod
+
+	// Ranges override the method for specific addresses. Later
+	// entries take precedence over earlier ones.
+	Ranges []ProbeRange
+
+	// Exclude lists addresses that are never touched.
+	Exclude []uint
+}
+
+// ScanResult describes an address found to be in use.
+type ScanResult struct {
+	Addr uint
+	// Method is the probe method used to detect the device.
+	Method ProbeMethod
+	// Busy indicates the address is claimed by a kernel driver,
+	// so it was not probed.
+	Busy bool
+}
+
+// method returns the probe method p selects for addr.
+func (p *ScanPolicy) method(addr uint) ProbeMethod {
+	m := ProbeAuto
+	if p != nil {
+		for _, x := range p.Exclude {
+			if x == addr {
+				return ProbeSkip
+			}
+		}
+		m = p.Method
+		for _, r := range p.Ranges {
+			if addr >= r.First && addr <= r.Last {
+				m = r.Method
+			}
+		}
+	}
+	if m != ProbeAuto {
+		return m
+	}
+	if p != nil && p.TenBit {
+		return ProbeQuick
+	}
+	if (addr >= 0x30 && addr <= 0x37) || (addr >= 0x50 && addr <= 0x5f) {
+		return ProbeRead
+	}
+	return ProbeQuick
+}
+
+// probe tests for the presence of a device at the current address of
+// c using method m. The caller must have exclusive use of c.
+func (c *Conn) probe(m ProbeMethod) error {
+	if m == ProbeRead {
+		var data smbusData
+		return c.smbus(smbusRead, 0, smbusByte, &data)
+	}
+	return c.smbus(smbusWrite, 0, smbusQuick, nil)
+}
+
+// Probe checks for a device at the connection's address with a
+// harmless transaction: an SMBus quick write or, if the adapter does
+// not support that, a receive byte. It returns nil if the device
+// responds. Otherwise, the error wraps the errno reported by the
+// adapter, typically syscall.ENXIO or syscall.EREMOTEIO for an
+// address nothing acknowledges.
+func (c *Conn) Probe() error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	m := ProbeQuick
+	if funcs, err := c.adapterFuncs(); err == nil && funcs&FUNC_SMBUS_QUICK == 0 {
+		m = ProbeRead
+	}
+	return c.probe(m)
+}
+
+// Probe checks for a device at addr on the bus device file, using the
+// probe method ProbeAuto selects for it, as Scan does: a receive byte
+// for the ranges where EEPROMs live, which a quick write can corrupt,
+// and an SMBus quick write otherwise. It reports true if the device
+// acknowledges, or the address is claimed by a kernel driver, and
+// false if nothing responds. Errors are returned only for failures to
+// use the bus.
+func Probe(bus string, addr uint, tenBit bool) (bool, error) {
+	c, err := openBus(bus)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+	if err := c.setAddr(addr, tenBit); errors.Is(err, syscall.EBUSY) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	m := (&ScanPolicy{TenBit: tenBit}).method(addr)
+	if err := c.probe(m); err == nil {
+		return true, nil
+	} else if !absent(err) {
+		return false, err
+	}
+	return false, nil
+}
+
+// absent indicates err is the result of no device responding, as
+// opposed to a failure of the adapter to perform the probe.
+func absent(err error) bool {
+	var eno syscall.Errno
+	if !errors.As(err, &eno) {
+		return false
+	}
+	for _, x := range absentErrnos {
+		if eno == x {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan probes the addresses of the bus device file selected by policy
+// and returns those that respond, or are claimed by a kernel driver.
+// A nil policy scans 0x08-0x77 with the ProbeAuto convention. The bus
+// is opened once, and its address changed for each probe.
+func Scan(bus string, policy *ScanPolicy) ([]ScanResult, error) {
+	first, last := uint(0x08), uint(0x77)
+	tenBit := policy != nil && policy.TenBit
+	if tenBit {
+		first, last = 0x000, 0x3ff
+	}
+	if policy != nil && (policy.First != 0 || policy.Last != 0) {
+		first, last = policy.First, policy.Last
+	}
+	c, err := openBus(bus)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	var found []ScanResult
+	for addr := first; addr <= last; addr++ {
+		m := policy.method(addr)
+		if m == ProbeSkip {
+			continue
+		}
+		if err := c.setAddr(addr, tenBit); errors.Is(err, syscall.EBUSY) {
+			found = append(found, ScanResult{Addr: addr, Method: ProbeSkip, Busy: true})
+			continue
+		} else if err != nil {
+			return found, err
+		}
+		if err := c.probe(m); err == nil {
+			found = append(found, ScanResult{Addr: addr, Method: m})
+		} else if !absent(err) {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// ScanAddrs probes addresses 0x03 through 0x77 of the bus device file
+// using method, and returns those that respond. ProbeAuto follows the
+// i2cdetect convention, while ProbeQuick and ProbeRead correspond to
+// its -q and -r options: some devices, write-only ones in particular,
+// are better probed with a read. Addresses claimed by a kernel driver
+// are not probed, and not returned. Use Scan for finer control.
+func ScanAddrs(bus string, method ProbeMethod) ([]uint, error) {
+	found, err := Scan(bus, &ScanPolicy{First: 0x03, Last: 0x77, Method: method})
+	var addrs []uint
+	for _, r := range found {
+		if !r.Busy {
+			addrs = append(addrs, r.Addr)
+		}
+	}
+	return addrs, err
+}
+
+// BusEvent reports a device appearing at, Added, or disappearing
+// from, an address of a bus.
+type BusEvent struct {
+	Addr  uint
+	Added bool
+}
+
+// watchDebounce is the number of consecutive scans that must agree
+// before WatchBus reports a change.
+const watchDebounce = 2
+
+// WatchBus scans the named bus device file every interval, with the
+// default ScanPolicy, and reports devices that appear or disappear,
+// for example as removable modules are connected. The devices present
+// initially are reported as added. A change must be seen by two
+// consecutive scans to be reported, so a device that misses a single
+// probe does not flap. Scans that fail are skipped. The channel is
+// closed when ctx is done.
+func WatchBus(ctx context.Context, bus string, interval time.Duration) (<-chan BusEvent, error) {
+	if interval <= 0 {
+		return nil, ErrInvalid
+	}
+	found, err := Scan(bus, nil)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[uint]bool)
+	var initial []BusEvent
+	for _, r := range found {
+		present[r.Addr] = true
+		initial = append(initial, BusEvent{Addr: r.Addr, Added: true})
+	}
+	ch := make(chan BusEvent, len(initial))
+	for _, ev := range initial {
+		ch <- ev
+	}
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		pending := make(map[uint]int)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			found, err := Scan(bus, nil)
+			if err != nil {
+				continue
+			}
+			now := make(map[uint]bool)
+			for _, r := range found {
+				now[r.Addr] = true
+			}
+			var events []BusEvent
+			for addr := uint(0); addr < 0x80; addr++ {
+				if now[addr] == present[addr] {
+					delete(pending, addr)
+					continue
+				}
+				if pending[addr]++; pending[addr] < watchDebounce {
+					continue
+				}
+				delete(pending, addr)
+				if now[addr] {
+					present[addr] = true
+				} else {
+					delete(present, addr)
+				}
+				events = append(events, BusEvent{Addr: addr, Added: now[addr]})
+			}
+			for _, ev := range events {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,248 @@
+package i2c
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestScanPolicyMethod(t *testing.T) {
+	p := &ScanPolicy{
+		Method:  ProbeRead,
+		Ranges:  []ProbeRange{{0x60, 0x6f, ProbeQuick}, {0x68, 0x68, ProbeAuto}},
+		Exclude: []uint{0x20},
+	}
+	vs := []struct {
+		p    *ScanPolicy
+		addr uint
+		want ProbeMethod
+	}{
+		{nil, 0x08, ProbeQuick},
+		{nil, 0x30, ProbeRead},
+		{nil, 0x37, ProbeRead},
+		{nil, 0x38, ProbeQuick},
+		{nil, 0x50, ProbeRead},
+		{nil, 0x5f, ProbeRead},
+		{nil, 0x60, ProbeQuick},
+		{&ScanPolicy{TenBit: true}, 0x50, ProbeQuick},
+		{p, 0x20, ProbeSkip},
+		{p, 0x21, ProbeRead},
+		{p, 0x60, ProbeQuick},
+		{p, 0x68, ProbeQuick},
+		{p, 0x70, ProbeRead},
+	}
+	for i, v := range vs {
+		if got := v.p.method(v.addr); got != v.want {
+			t.Errorf("%d: method(%#x) got %v, want %v", i, v.addr, got, v.want)
+		}
+	}
+}
+
+// probes returns the probe method used for each address in ops.
+func probes(ops []string) map[uint]ProbeMethod {
+	m := make(map[uint]ProbeMethod)
+	for _, op := range ops {
+		var addr uint
+		var rw string
+		var cmd, size int
+		if _, err := fmt.Sscanf(op, "smbus %x: %s %x size=%d", &addr, &rw, &cmd, &size); err != nil {
+			continue
+		}
+		switch {
+		case rw == "w" && size == smbusQuick:
+			m[addr] = ProbeQuick
+		case rw == "r" && size == smbusByte:
+			m[addr] = ProbeRead
+		}
+	}
+	return m
+}
+
+func TestScan(t *testing.T) {
+	a := newFakeAdapter(0x20, 0x50, 0x68)
+	a.busy[0x3c] = true
+	bus := useFake(t, a, 1)
+
+	found, err := Scan(bus, nil)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	want := []ScanResult{
+		{Addr: 0x20, Method: ProbeQuick},
+		{Addr: 0x3c, Method: ProbeSkip, Busy: true},
+		{Addr: 0x50, Method: ProbeRead},
+		{Addr: 0x68, Method: ProbeQuick},
+	}
+	if fmt.Sprint(found) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", found, want)
+	}
+	used := probes(a.ops())
+	for addr := uint(0x08); addr <= 0x77; addr++ {
+		want := (*ScanPolicy)(nil).method(addr)
+		if addr == 0x3c {
+			want = ProbeAuto
+		}
+		if got, ok := used[addr]; want == ProbeAuto && ok {
+			t.Errorf("busy address %#x probed", addr)
+		} else if want != ProbeAuto && got != want {
+			t.Errorf("address %#x probed with %v, want %v", addr, got, want)
+		}
+	}
+
+	p := &ScanPolicy{
+		First:   0x10,
+		Last:    0x6f,
+		Method:  ProbeRead,
+		Ranges:  []ProbeRange{{0x60, 0x6f, ProbeQuick}},
+		Exclude: []uint{0x20},
+	}
+	found, err = Scan(bus, p)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	want = []ScanResult{
+		{Addr: 0x3c, Method: ProbeSkip, Busy: true},
+		{Addr: 0x50, Method: ProbeRead},
+		{Addr: 0x68, Method: ProbeQuick},
+	}
+	if fmt.Sprint(found) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", found, want)
+	}
+	ops := a.ops()
+	for _, op := range ops {
+		if strings.Contains(op, " 20:") {
+			t.Errorf("excluded address touched: %q", op)
+		}
+	}
+	used = probes(ops)
+	for addr := uint(0x10); addr <= 0x6f; addr++ {
+		if addr == 0x20 || addr == 0x3c {
+			continue
+		}
+		if got, want := used[addr], p.method(addr); got != want {
+			t.Errorf("address %#x probed with %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+	a := newFakeAdapter(0x20, 0x50)
+	a.busy[0x3c] = true
+	bus := useFake(t, a, 1)
+	vs := []struct {
+		addr   uint
+		found  bool
+		method ProbeMethod
+	}{
+		{0x20, true, ProbeQuick},
+		{0x21, false, ProbeQuick},
+		{0x3c, true, ProbeAuto},
+		{0x50, true, ProbeRead},
+		{0x51, false, ProbeRead},
+	}
+	for _, v := range vs {
+		found, err := Probe(bus, v.addr, false)
+		if err != nil || found != v.found {
+			t.Errorf("Probe(%#x) got %v, %v, want %v", v.addr, found, err, v.found)
+		}
+		used := probes(a.ops())
+		if got, ok := used[v.addr]; v.method == ProbeAuto && ok {
+			t.Errorf("busy address %#x probed", v.addr)
+		} else if v.method != ProbeAuto && got != v.method {
+			t.Errorf("Probe(%#x) used %v, want %v", v.addr, got, v.method)
+		}
+	}
+}
+
+func TestConnProbe(t *testing.T) {
+	a := newFakeAdapter(0x20)
+	c := newFakeConn(t, a, 0x20, nil)
+	if err := c.Probe(); err != nil {
+		t.Errorf("Probe of present device failed: %v", err)
+	}
+	if err := c.SetAddr(0x21, false); err != nil {
+		t.Fatal(err)
+	}
+	err := c.Probe()
+	if !errors.Is(err, syscall.ENXIO) || !absent(err) {
+		t.Errorf("Probe of absent device got %v, want ENXIO", err)
+	}
+	a.funcs &^= FUNC_SMBUS_QUICK
+	c = newFakeConn(t, a, 0x20, nil)
+	a.ops()
+	if err := c.Probe(); err != nil {
+		t.Errorf("Probe without quick support failed: %v", err)
+	}
+	if got := probes(a.ops()); got[0x20] != ProbeRead {
+		t.Errorf("Probe without quick support used %v, want read", got[0x20])
+	}
+}
+
+func TestWatchBus(t *testing.T) {
+	a := newFakeAdapter(0x20)
+	bus := useFake(t, a, 1)
+	// The device at 0x21 appears, misses a single scan, then
+	// disappears. Each scan starts by selecting address 0x08.
+	seen := []bool{false, true, true, false, true, false, false}
+	scans := 0
+	dev := &fakeDev{}
+	a.onAddr = func(addr uint) {
+		if addr != 0x08 {
+			return
+		}
+		if scans < len(seen) && seen[scans] {
+			a.devs[0x21] = dev
+		} else {
+			delete(a.devs, 0x21)
+		}
+		scans++
+	}
+
+	if _, err := WatchBus(context.Background(), bus, 0); !errors.Is(err, ErrInvalid) {
+		t.Errorf("zero interval: got %v, want %v", err, ErrInvalid)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := WatchBus(ctx, bus, time.Millisecond)
+	if err != nil {
+		t.Fatalf("WatchBus failed: %v", err)
+	}
+	want := []BusEvent{
+		{Addr: 0x20, Added: true},
+		{Addr: 0x21, Added: true},
+		{Addr: 0x21, Added: false},
+	}
+	for i, w := range want {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				t.Fatalf("event %d: channel closed", i)
+			}
+			if ev != w {
+				t.Errorf("event %d: got %+v, want %+v", i, ev, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %d: timed out waiting for %+v", i, w)
+		}
+	}
+	a.mu.Lock()
+	n := scans
+	a.mu.Unlock()
+	if n < len(seen) {
+		t.Errorf("removal reported after %d scans, want %d", n, len(seen))
+	}
+
+	cancel()
+	select {
+	case ev, ok := <-ch:
+		if ok {
+			t.Errorf("unexpected event %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+}
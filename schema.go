@@ -0,0 +1,72 @@
+package i2c
+
+import (
+	"fmt"
+	"math"
+)
+
+// Field describes one value in a block of device registers. Type is
+// one of "int8", "int16", "int24", "int32", "int64", "float32" or
+// "float64". For the integer types, Signed selects a two's complement
+// interpretation of the value.
+type Field struct {
+	Name   string
+	Type   string
+	Signed bool
+}
+
+// fieldSizes holds the number of bytes occupied by each Field.Type.
+var fieldSizes = map[string]int{
+	"int8":    1,
+	"int16":   2,
+	"int24":   3,
+	"int32":   4,
+	"int64":   8,
+	"float32": 4,
+	"float64": 8,
+}
+
+// ReadSchema reads a block of registers starting at reg and decodes
+// it, in the connection's byte order, into the sequence of values
+// described by fields. The result maps each field name to its value:
+// an int64 for signed integers, a uint64 for unsigned integers and a
+// float64 for floating point fields. This permits generic tools to
+// decode devices described in a configuration file.
+func (c *Conn) ReadSchema(reg byte, fields []Field) (map[string]any, error) {
+	order, err := c.byteOrder()
+	if err != nil {
+		return nil, err
+	}
+	total := 0
+	for i, f := range fields {
+		n, ok := fieldSizes[f.Type]
+		if !ok {
+			return nil, fmt.Errorf("field %d (%q) has invalid type %q: %w", i, f.Name, f.Type, ErrInvalid)
+		}
+		total += n
+	}
+	if total == 0 || total > maxMsg {
+		return nil, fmt.Errorf("schema size %d bytes is out of range: %w", total, ErrInvalid)
+	}
+	d := make([]byte, total)
+	if _, err := c.ReadRegBuf(reg, d); err != nil {
+		return nil, err
+	}
+	vals := make(map[string]any, len(fields))
+	for _, f := range fields {
+		n := fieldSizes[f.Type]
+		u := decodeUint(order, d[:n])
+		d = d[n:]
+		switch {
+		case f.Type == "float32":
+			vals[f.Name] = float64(math.Float32frombits(uint32(u)))
+		case f.Type == "float64":
+			vals[f.Name] = math.Float64frombits(u)
+		case f.Signed:
+			vals[f.Name] = signExtend(u, n)
+		default:
+			vals[f.Name] = u
+		}
+	}
+	return vals, nil
+}
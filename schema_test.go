@@ -0,0 +1,88 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReadSchema(t *testing.T) {
+	fields := []Field{
+		{Name: "status", Type: "int8"},
+		{Name: "temp", Type: "int16", Signed: true},
+		{Name: "count", Type: "int24"},
+		{Name: "offset", Type: "int24", Signed: true},
+		{Name: "ratio", Type: "float32"},
+		{Name: "total", Type: "int64", Signed: true},
+	}
+	be := []byte{
+		0x81,
+		0xff, 0x38,
+		0x01, 0x02, 0x03,
+		0xff, 0xff, 0xfe,
+		0x3f, 0xc0, 0x00, 0x00,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xf6,
+	}
+	want := map[string]any{
+		"status": uint64(0x81),
+		"temp":   int64(-200),
+		"count":  uint64(0x010203),
+		"offset": int64(-2),
+		"ratio":  1.5,
+		"total":  int64(-10),
+	}
+	// The little endian layout of the same values reverses the bytes
+	// of each field.
+	var le []byte
+	rest := be
+	for _, f := range fields {
+		n := fieldSizes[f.Type]
+		le = append(le, reversed(rest[:n])...)
+		rest = rest[n:]
+	}
+	for _, v := range []struct {
+		order binary.ByteOrder
+		regs  []byte
+	}{
+		{binary.BigEndian, be},
+		{binary.LittleEndian, le},
+	} {
+		a := newFakeAdapter(0x40)
+		copy(a.devs[0x40].regs[0x20:], v.regs)
+		c := newFakeConn(t, a, 0x40, v.order)
+		got, err := c.ReadSchema(0x20, fields)
+		if err != nil {
+			t.Errorf("%v: ReadSchema failed: %v", v.order, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %v, want %v", v.order, got, want)
+		}
+		if ops := a.ops(); !equalStrings(ops, []string{"w 40: 20", "r 40: 21"}) {
+			t.Errorf("%v: got %q, want one 21 byte read", v.order, ops)
+		}
+	}
+}
+
+func TestReadSchemaInvalid(t *testing.T) {
+	a := newFakeAdapter(0x40)
+	c := newFakeConn(t, a, 0x40, binary.BigEndian)
+	vs := [][]Field{
+		nil,
+		{{Name: "x", Type: "int12"}},
+		{{Name: "x", Type: "uint16"}},
+		make([]Field, maxMsg/8+1),
+	}
+	for i := range vs[3] {
+		vs[3][i] = Field{Name: "x", Type: "float64"}
+	}
+	for i, fields := range vs {
+		if _, err := c.ReadSchema(0, fields); !errors.Is(err, ErrInvalid) {
+			t.Errorf("test=%d: got %v, want ErrInvalid", i, err)
+		}
+	}
+	if ops := a.ops(); len(ops) != 0 {
+		t.Errorf("invalid schemas read the device: %q", ops)
+	}
+}
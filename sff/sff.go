@@ -0,0 +1,381 @@
+// Package sff decodes the identification and diagnostic memory of
+// pluggable optical transceivers.
+//
+// SFP modules follow SFF-8472, exposing their identity at i2c address
+// 0x50 (A0h) and, when digital diagnostic monitoring (DDM) is
+// implemented, live measurements at 0x51 (A2h). QSFP modules follow
+// SFF-8636, exposing both in 256 bytes at 0x50, of which this
+// package decodes the lower page and upper page 00h.
+package sff // zappem.net/pub/io/i2c/sff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"zappem.net/pub/io/i2c"
+)
+
+// IDAddr and DiagAddr are the i2c addresses of the module's
+// identification and diagnostic memories.
+const (
+	IDAddr   = 0x50
+	DiagAddr = 0x51
+)
+
+// ErrChecksum etc are errors reported by the package.
+var (
+	ErrChecksum = errors.New("checksum mismatch")
+	ErrNoDDM    = errors.New("module does not implement diagnostics")
+	ErrModule   = errors.New("unsupported module type")
+)
+
+// identifiers holds the names of the module identifier values.
+var identifiers = map[byte]string{
+	0x03: "SFP/SFP+",
+	0x0c: "QSFP",
+	0x0d: "QSFP+",
+	0x11: "QSFP28",
+}
+
+// connectors holds the names of the connector type values.
+var connectors = map[byte]string{
+	0x01: "SC",
+	0x07: "LC",
+	0x0b: "Optical pigtail",
+	0x0c: "MPO 1x12",
+	0x0d: "MPO 2x16",
+	0x21: "Copper pigtail",
+	0x22: "RJ45",
+	0x23: "No separable connector",
+	0x24: "MXC 2x16",
+}
+
+// codeBit names one bit of a compliance code field, by its offset
+// from the start of the field.
+type codeBit struct {
+	off  int
+	bit  byte
+	name string
+}
+
+// compliance names the SFF-8472 transceiver compliance code bits of
+// SFP modules, bytes 3-10 of A0h.
+var compliance = []codeBit{
+	{0, 1 << 4, "10GBASE-SR"},
+	{0, 1 << 5, "10GBASE-LR"},
+	{0, 1 << 6, "10GBASE-LRM"},
+	{0, 1 << 7, "10GBASE-ER"},
+	{3, 1 << 0, "1000BASE-SX"},
+	{3, 1 << 1, "1000BASE-LX"},
+	{3, 1 << 2, "1000BASE-CX"},
+	{3, 1 << 3, "1000BASE-T"},
+	{5, 1 << 2, "Passive cable"},
+	{5, 1 << 3, "Active cable"},
+}
+
+// qsfpCompliance names the SFF-8636 specification compliance code
+// bits of QSFP modules, bytes 131-138. Bit 7 of byte 131 indicates
+// the code is instead given by the extended compliance code byte.
+var qsfpCompliance = []codeBit{
+	{0, 1 << 0, "40G Active Cable (XLPPI)"},
+	{0, 1 << 1, "40GBASE-LR4"},
+	{0, 1 << 2, "40GBASE-SR4"},
+	{0, 1 << 3, "40GBASE-CR4"},
+	{0, 1 << 4, "10GBASE-SR"},
+	{0, 1 << 5, "10GBASE-LR"},
+	{0, 1 << 6, "10GBASE-LRM"},
+	{1, 1 << 0, "OC 48 short reach"},
+	{1, 1 << 1, "OC 48 intermediate reach"},
+	{1, 1 << 2, "OC 48 long reach"},
+	{2, 1 << 4, "SAS 3.0 Gbps"},
+	{2, 1 << 5, "SAS 6.0 Gbps"},
+	{2, 1 << 6, "SAS 12.0 Gbps"},
+	{2, 1 << 7, "SAS 24.0 Gbps"},
+	{3, 1 << 0, "1000BASE-SX"},
+	{3, 1 << 1, "1000BASE-LX"},
+	{3, 1 << 2, "1000BASE-CX"},
+	{3, 1 << 3, "1000BASE-T"},
+}
+
+// extCompliance holds the names of the SFF-8024 extended compliance
+// code values used by QSFP modules.
+var extCompliance = map[byte]string{
+	0x01: "100G AOC or 25GAUI C2M AOC",
+	0x02: "100GBASE-SR4 or 25GBASE-SR",
+	0x03: "100GBASE-LR4 or 25GBASE-LR",
+	0x04: "100GBASE-ER4 or 25GBASE-ER",
+	0x05: "100GBASE-SR10",
+	0x06: "100G CWDM4",
+	0x07: "100G PSM4 Parallel SMF",
+	0x08: "100G ACC or 25GAUI C2M ACC",
+	0x0b: "100GBASE-CR4 or 25GBASE-CR CA-L",
+	0x0c: "25GBASE-CR CA-S",
+	0x0d: "25GBASE-CR CA-N",
+	0x10: "40GBASE-ER4",
+	0x11: "4 x 10GBASE-SR",
+	0x12: "40G PSM4 Parallel SMF",
+	0x17: "100G CLR4",
+	0x1a: "100GE-DWDM2",
+}
+
+// Info holds the identity of a module.
+type Info struct {
+	Identifier byte
+	Type       string
+	Connector  string
+	Compliance []string
+	VendorName string
+	VendorOUI  [3]byte
+	VendorPN   string
+	VendorRev  string
+	VendorSN   string
+	DateCode   string
+	// Wavelength is the nominal laser wavelength in nm. It is zero
+	// for copper modules.
+	Wavelength float64
+	// DDM indicates the module implements diagnostics, and
+	// ExternalCal that they need the external calibration
+	// constants applied.
+	DDM         bool
+	ExternalCal bool
+}
+
+// Diagnostics holds the live measurements of a module. The per
+// channel values have one entry for an SFP and four for a QSFP.
+type Diagnostics struct {
+	// Temperature is in degrees Celsius.
+	Temperature float64
+	// Voltage is the supply voltage in V.
+	Voltage float64
+	// TxBias is the laser bias current in mA.
+	TxBias []float64
+	// TxPower and RxPower are optical powers in mW.
+	TxPower []float64
+	RxPower []float64
+}
+
+// checksum verifies that the low byte of the sum of d equals want.
+func checksum(name string, d []byte, want byte) error {
+	var sum byte
+	for _, b := range d {
+		sum += b
+	}
+	if sum != want {
+		return fmt.Errorf("%s %w: got %02x want %02x", name, ErrChecksum, sum, want)
+	}
+	return nil
+}
+
+// text decodes a space padded ASCII field.
+func text(d []byte) string {
+	return strings.TrimRight(string(d), " \x00")
+}
+
+// name returns the name of a code, or its value if unknown.
+func name(names map[byte]string, code byte) string {
+	if n, ok := names[code]; ok {
+		return n
+	}
+	return fmt.Sprintf("unknown (%02xh)", code)
+}
+
+// codes lists the set bits, named by table, of a compliance code
+// field.
+func codes(table []codeBit, d []byte) []string {
+	var s []string
+	for _, c := range table {
+		if d[c.off]&c.bit != 0 {
+			s = append(s, c.name)
+		}
+	}
+	return s
+}
+
+// DecodeSFP decodes the first 96 bytes of an SFP module's A0h memory,
+// verifying the CC_BASE and CC_EXT checksums.
+func DecodeSFP(a0 []byte) (*Info, error) {
+	if len(a0) < 96 {
+		return nil, i2c.ErrTruncated
+	}
+	if err := checksum("CC_BASE", a0[0:63], a0[63]); err != nil {
+		return nil, err
+	}
+	if err := checksum("CC_EXT", a0[64:95], a0[95]); err != nil {
+		return nil, err
+	}
+	info := &Info{
+		Identifier:  a0[0],
+		Type:        name(identifiers, a0[0]),
+		Connector:   name(connectors, a0[2]),
+		Compliance:  codes(compliance, a0[3:11]),
+		VendorName:  text(a0[20:36]),
+		VendorPN:    text(a0[40:56]),
+		VendorRev:   text(a0[56:60]),
+		VendorSN:    text(a0[68:84]),
+		DateCode:    text(a0[84:92]),
+		DDM:         a0[92]&(1<<6) != 0,
+		ExternalCal: a0[92]&(1<<4) != 0,
+	}
+	copy(info.VendorOUI[:], a0[37:40])
+	if a0[8]&0x0c == 0 {
+		// For copper cables, these bytes describe the cable
+		// compliance instead.
+		info.Wavelength = float64(binary.BigEndian.Uint16(a0[60:62]))
+	}
+	return info, nil
+}
+
+// float32At decodes a big endian IEEE-754 value.
+func float32At(d []byte) float64 {
+	return float64(math.Float32frombits(binary.BigEndian.Uint32(d)))
+}
+
+// cal applies a linear external calibration with an unsigned 8.8
+// fixed point slope and a signed offset to the raw value v.
+func cal(v float64, slope, offset []byte) float64 {
+	s := float64(binary.BigEndian.Uint16(slope)) / 256
+	o := float64(int16(binary.BigEndian.Uint16(offset)))
+	return s*v + o
+}
+
+// DecodeSFPDiagnostics decodes the first 128 bytes of an SFP module's
+// A2h memory, applying external calibration if info indicates it is
+// required. The CC_DMI checksum is verified.
+func DecodeSFPDiagnostics(info *Info, a2 []byte) (*Diagnostics, error) {
+	if !info.DDM {
+		return nil, ErrNoDDM
+	}
+	if len(a2) < 106 {
+		return nil, i2c.ErrTruncated
+	}
+	if err := checksum("CC_DMI", a2[0:95], a2[95]); err != nil {
+		return nil, err
+	}
+	word := func(off int) uint16 {
+		return binary.BigEndian.Uint16(a2[off : off+2])
+	}
+	t, v, bias, tx, rx := word(96), word(98), word(100), word(102), word(104)
+	temp := float64(int16(t))
+	volt, amps, txp, rxp := float64(v), float64(bias), float64(tx), float64(rx)
+	if info.ExternalCal {
+		temp = cal(temp, a2[84:86], a2[86:88])
+		volt = cal(volt, a2[88:90], a2[90:92])
+		amps = cal(amps, a2[76:78], a2[78:80])
+		txp = cal(txp, a2[80:82], a2[82:84])
+		rxp = float32At(a2[72:76])
+		for i, p := 1, float64(rx); i <= 4; i, p = i+1, p*float64(rx) {
+			rxp += float32At(a2[72-4*i:76-4*i]) * p
+		}
+	}
+	return &Diagnostics{
+		Temperature: temp / 256,
+		Voltage:     volt * 100e-6,
+		TxBias:      []float64{amps * 2e-3},
+		TxPower:     []float64{txp * 1e-4},
+		RxPower:     []float64{rxp * 1e-4},
+	}, nil
+}
+
+// DecodeQSFP decodes the 256 bytes of a QSFP module's lower page and
+// upper page 00h, verifying the CC_BASE and CC_EXT checksums.
+// Diagnostics are always present for these modules.
+func DecodeQSFP(d []byte) (*Info, *Diagnostics, error) {
+	if len(d) < 256 {
+		return nil, nil, i2c.ErrTruncated
+	}
+	if err := checksum("CC_BASE", d[128:191], d[191]); err != nil {
+		return nil, nil, err
+	}
+	if err := checksum("CC_EXT", d[192:223], d[223]); err != nil {
+		return nil, nil, err
+	}
+	info := &Info{
+		Identifier: d[128],
+		Type:       name(identifiers, d[128]),
+		Connector:  name(connectors, d[130]),
+		Compliance: codes(qsfpCompliance, d[131:139]),
+		VendorName: text(d[148:164]),
+		VendorPN:   text(d[168:184]),
+		VendorRev:  text(d[184:186]),
+		VendorSN:   text(d[196:212]),
+		DateCode:   text(d[212:220]),
+		DDM:        true,
+	}
+	if d[131]&(1<<7) != 0 {
+		info.Compliance = append(info.Compliance, name(extCompliance, d[192]))
+	}
+	copy(info.VendorOUI[:], d[165:168])
+	if d[147]>>4 < 0xa {
+		// Optical media: wavelength is in units of 0.05 nm.
+		info.Wavelength = float64(binary.BigEndian.Uint16(d[186:188])) / 20
+	}
+	word := func(off int) uint16 {
+		return binary.BigEndian.Uint16(d[off : off+2])
+	}
+	diag := &Diagnostics{
+		Temperature: float64(int16(word(22))) / 256,
+		Voltage:     float64(word(26)) * 100e-6,
+	}
+	for ch := 0; ch < 4; ch++ {
+		diag.RxPower = append(diag.RxPower, float64(word(34+2*ch))*1e-4)
+		diag.TxBias = append(diag.TxBias, float64(word(42+2*ch))*2e-3)
+		diag.TxPower = append(diag.TxPower, float64(word(50+2*ch))*1e-4)
+	}
+	return info, diag, nil
+}
+
+// Read reads and decodes a module. The id memory is the module's A0h
+// memory, and diag, for SFP modules, its A2h memory. Either may be
+// an *i2c.EEPROM when the kernel's sfp or optoe driver owns the
+// module, or the result of Mem otherwise. For modules without
+// diagnostics, the returned Diagnostics are nil.
+func Read(id, diag io.ReaderAt) (*Info, *Diagnostics, error) {
+	d := make([]byte, 256)
+	if _, err := id.ReadAt(d[:128], 0); err != nil {
+		return nil, nil, err
+	}
+	switch d[0] {
+	case 0x03:
+		info, err := DecodeSFP(d[:128])
+		if err != nil {
+			return nil, nil, err
+		}
+		if !info.DDM || diag == nil {
+			return info, nil, nil
+		}
+		if _, err := diag.ReadAt(d[128:], 0); err != nil {
+			return info, nil, err
+		}
+		dm, err := DecodeSFPDiagnostics(info, d[128:])
+		return info, dm, err
+	case 0x0c, 0x0d, 0x11:
+		if _, err := id.ReadAt(d[128:], 128); err != nil {
+			return nil, nil, err
+		}
+		return DecodeQSFP(d)
+	}
+	return nil, nil, fmt.Errorf("%w: identifier %02xh", ErrModule, d[0])
+}
+
+// mem is an io.ReaderAt over a 256 byte module memory.
+type mem struct {
+	c *i2c.Conn
+}
+
+// ReadAt reads len(p) bytes of the module memory from offset off.
+func (m mem) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > 256 {
+		return 0, i2c.ErrInvalid
+	}
+	return m.c.ReadRegBuf(byte(off), p)
+}
+
+// Mem returns an io.ReaderAt view of the 256 byte memory of a module
+// at the address of c.
+func Mem(c *i2c.Conn) io.ReaderAt {
+	return mem{c: c}
+}
@@ -0,0 +1,182 @@
+package sff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"zappem.net/pub/io/i2c"
+)
+
+// image reads a module memory image from a hex dump in testdata.
+// Each line holds an offset, a colon and up to 16 bytes in hex. Lines
+// starting with # are comments.
+func image(t *testing.T, name string) []byte {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to open image: %v", err)
+	}
+	defer f.Close()
+	var d []byte
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		_, data, ok := strings.Cut(line, ":")
+		if !ok {
+			t.Fatalf("%s: bad line %q", name, line)
+		}
+		b, err := hex.DecodeString(strings.ReplaceAll(data, " ", ""))
+		if err != nil {
+			t.Fatalf("%s: bad line %q: %v", name, line, err)
+		}
+		d = append(d, b...)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	return d
+}
+
+// near compares measurements to a precision finer than their units.
+func near(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReadSFP(t *testing.T) {
+	d := image(t, "sfp-10gbase-sr.hex")
+	info, diag, err := Read(bytes.NewReader(d[:256]), bytes.NewReader(d[256:]))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	want := &Info{
+		Identifier: 0x03,
+		Type:       "SFP/SFP+",
+		Connector:  "LC",
+		Compliance: []string{"10GBASE-SR"},
+		VendorName: "FINISAR CORP.",
+		VendorOUI:  [3]byte{0x00, 0x90, 0x65},
+		VendorPN:   "FTLX8571D3BCL",
+		VendorRev:  "A",
+		VendorSN:   "AKR0ABC",
+		DateCode:   "150324",
+		Wavelength: 850,
+		DDM:        true,
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+	if diag.Temperature != 34.5 || !near([]float64{diag.Voltage}, []float64{3.2904}) {
+		t.Errorf("got %v C and %v V, want 34.5 C and 3.2904 V", diag.Temperature, diag.Voltage)
+	}
+	if !near(diag.TxBias, []float64{12}) || !near(diag.TxPower, []float64{0.6146}) || !near(diag.RxPower, []float64{0.5}) {
+		t.Errorf("got bias=%v tx=%v rx=%v", diag.TxBias, diag.TxPower, diag.RxPower)
+	}
+}
+
+func TestReadQSFP(t *testing.T) {
+	vs := []struct {
+		image      string
+		typ        string
+		pn         string
+		compliance []string
+		temp, volt float64
+		rx, bias   []float64
+		tx         []float64
+	}{
+		{
+			image:      "qsfp28-100gbase-sr4.hex",
+			typ:        "QSFP28",
+			pn:         "FTLC9551REPM",
+			compliance: []string{"100GBASE-SR4 or 25GBASE-SR"},
+			temp:       31,
+			volt:       3.257,
+			rx:         []float64{1, 0.95, 0.98, 1.02},
+			bias:       []float64{14, 14.2, 13.8, 14.1},
+			tx:         []float64{0.75, 0.74, 0.76, 0.745},
+		},
+		{
+			image:      "qsfp-40gbase-sr4.hex",
+			typ:        "QSFP+",
+			pn:         "FTL410QE2C",
+			compliance: []string{"40GBASE-SR4"},
+			temp:       26.5,
+			volt:       3.3,
+			rx:         []float64{0.6, 0.61, 0.62, 0.63},
+			bias:       []float64{6, 6.2, 6.4, 6.6},
+			tx:         []float64{0.5, 0.51, 0.52, 0.53},
+		},
+	}
+	for _, v := range vs {
+		info, diag, err := Read(bytes.NewReader(image(t, v.image)), nil)
+		if err != nil {
+			t.Errorf("%s: Read failed: %v", v.image, err)
+			continue
+		}
+		if info.Type != v.typ || info.VendorPN != v.pn || info.Connector != "MPO 1x12" || info.Wavelength != 850 {
+			t.Errorf("%s: got %+v", v.image, info)
+		}
+		if !reflect.DeepEqual(info.Compliance, v.compliance) {
+			t.Errorf("%s: got compliance %q, want %q", v.image, info.Compliance, v.compliance)
+		}
+		if diag.Temperature != v.temp || !near([]float64{diag.Voltage}, []float64{v.volt}) {
+			t.Errorf("%s: got %v C and %v V, want %v C and %v V", v.image, diag.Temperature, diag.Voltage, v.temp, v.volt)
+		}
+		if !near(diag.RxPower, v.rx) || !near(diag.TxBias, v.bias) || !near(diag.TxPower, v.tx) {
+			t.Errorf("%s: got rx=%v bias=%v tx=%v", v.image, diag.RxPower, diag.TxBias, diag.TxPower)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	sfp := image(t, "sfp-10gbase-sr.hex")
+	qsfp := image(t, "qsfp28-100gbase-sr4.hex")
+	corrupt := func(d []byte, off int) []byte {
+		d = append([]byte(nil), d...)
+		d[off] ^= 0x01
+		return d
+	}
+	if _, err := DecodeSFP(corrupt(sfp, 30)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("SFP CC_BASE got %v, want ErrChecksum", err)
+	}
+	if _, err := DecodeSFP(corrupt(sfp, 70)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("SFP CC_EXT got %v, want ErrChecksum", err)
+	}
+	info, err := DecodeSFP(sfp)
+	if err != nil {
+		t.Fatalf("DecodeSFP failed: %v", err)
+	}
+	if _, err := DecodeSFPDiagnostics(info, corrupt(sfp[256:], 2)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("SFP CC_DMI got %v, want ErrChecksum", err)
+	}
+	if _, err := DecodeSFPDiagnostics(&Info{}, sfp[256:]); err != ErrNoDDM {
+		t.Errorf("SFP without DDM got %v, want ErrNoDDM", err)
+	}
+	if _, _, err := DecodeQSFP(corrupt(qsfp, 150)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("QSFP CC_BASE got %v, want ErrChecksum", err)
+	}
+	if _, _, err := DecodeQSFP(qsfp[:200]); err != i2c.ErrTruncated {
+		t.Errorf("short QSFP got %v, want ErrTruncated", err)
+	}
+	if _, _, err := Read(bytes.NewReader(make([]byte, 256)), nil); !errors.Is(err, ErrModule) {
+		t.Errorf("unknown module got %v, want ErrModule", err)
+	}
+}
@@ -0,0 +1,431 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// smbusWrite etc are from /usr/include/linux/i2c.h and describe the
+// direction and kind of an SMBus transaction.
+const (
+	smbusWrite = 0
+	smbusRead  = 1
+
+	smbusQuick         = 0
+	smbusByte          = 1
+	smbusByteData      = 2
+	smbusWordData      = 3
+	smbusProcCall      = 4
+	smbusBlockData     = 5
+	smbusBlockProcCall = 7
+	smbusI2CBlockData  = 8
+
+	smbusBlockMax = 32
+)
+
+// ErrPEC indicates an SMBus transaction failed its packet error check.
+var ErrPEC = errors.New("packet error check failed")
+
+// hostEndian is the byte order of the host, which is how the kernel
+// stores word values in i2c_smbus_data.
+var hostEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		hostEndian = binary.BigEndian
+	}
+}
+
+// smbusData mirrors the kernel's union i2c_smbus_data. It is large
+// enough for a block transfer: a length byte, the block, and room for
+// a PEC byte.
+type smbusData [smbusBlockMax + 2]byte
+
+// smbusIoctlData mirrors the kernel's struct i2c_smbus_ioctl_data.
+type smbusIoctlData struct {
+	readWrite uint8
+	command   uint8
+	size      uint32
+	data      *smbusData
+}
+
+// smbus performs a single SMBus transaction of the indicated size on
+// the open connection. The caller must hold c.mu or otherwise have
+// exclusive use of c.
+func (c *Conn) smbus(readWrite, command uint8, size uint32, data *smbusData) error {
+	if c.preamble != nil && size != smbusQuick && size != smbusByte {
+		return fmt.Errorf("SMBus register %02xh access with a preamble: %w", command, ErrUnsupported)
+	}
+	args := smbusIoctlData{
+		readWrite: readWrite,
+		command:   command,
+		size:      size,
+		data:      data,
+	}
+	c.pace()
+	err := c.ioctlPtr(SMBUS, unsafe.Pointer(&args))
+	if errors.Is(err, syscall.EBADMSG) {
+		err = fmt.Errorf("%w: %w", ErrPEC, err)
+	}
+	return err
+}
+
+// wordFromWire decodes an SMBus word, w, in the connection's byte
+// order. SMBus transfers the low byte of w first, so this is w itself
+// for a little endian connection and w byte swapped for a big endian
+// one.
+func (c *Conn) wordFromWire(w uint16) uint16 {
+	d := [2]byte{byte(w), byte(w >> 8)}
+	return c.endian.Uint16(d[:])
+}
+
+// wordToWire encodes val in the connection's byte order and returns
+// it as an SMBus word, whose low byte is transferred first.
+func (c *Conn) wordToWire(val uint16) uint16 {
+	var d [2]byte
+	c.endian.PutUint16(d[:], val)
+	return uint16(d[0]) | uint16(d[1])<<8
+}
+
+// SetPEC enables or disables SMBus packet error checking (PEC) for
+// the connection's bus device file. When enabled, the kernel appends
+// a CRC-8 byte to the SMBus transactions that carry data, that is all
+// but quick transactions, verifies the byte sent by the device on
+// reads, and reports a mismatch as an error wrapping ErrPEC, all
+// transparently to the SMBus methods. It has no effect on plain i2c
+// reads and writes, or on Transaction. The adapter must support
+// FUNC_SMBUS_PEC, and the device must implement PEC.
+func (c *Conn) SetPEC(on bool) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	var arg uintptr
+	if on {
+		arg = 1
+	}
+	if err := c.ioctl(PEC, arg); err != nil {
+		return err
+	}
+	c.pec = on
+	return nil
+}
+
+// SMBusReadWordData performs an SMBus read word data transaction,
+// reading a 16-bit value from register reg. SMBus defines words as
+// little endian on the wire, and the kernel returns them as such. The
+// byte order of the connection is applied after this, to the two
+// bytes in the order they were transferred: a binary.LittleEndian
+// connection gets the SMBus value unchanged, while a binary.BigEndian
+// connection gets it byte swapped, treating the first byte on the
+// wire as the most significant.
+func (c *Conn) SMBusReadWordData(reg byte) (uint16, error) {
+	if _, err := c.byteOrder(); err != nil {
+		return 0, err
+	}
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	var data smbusData
+	if err := c.smbus(smbusRead, reg, smbusWordData, &data); err != nil {
+		return 0, err
+	}
+	return c.wordFromWire(hostEndian.Uint16(data[:2])), nil
+}
+
+// SMBusWriteWordData performs an SMBus write word data transaction,
+// writing val to register reg. As for SMBusReadWordData, val is
+// encoded in the connection's byte order to determine the order in
+// which its two bytes are transferred.
+func (c *Conn) SMBusWriteWordData(reg byte, val uint16) error {
+	if _, err := c.byteOrder(); err != nil {
+		return err
+	}
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	var data smbusData
+	hostEndian.PutUint16(data[:2], c.wordToWire(val))
+	return c.smbus(smbusWrite, reg, smbusWordData, &data)
+}
+
+// SMBusReadBlock performs an SMBus block read from register reg. The
+// device supplies the length of the block, of at most 32 bytes, and
+// a slice of exactly that length is returned. A longer length, which
+// the block cannot hold, results in an error wrapping ErrTruncated.
+func (c *Conn) SMBusReadBlock(reg byte) ([]byte, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	var data smbusData
+	if err := c.smbus(smbusRead, reg, smbusBlockData, &data); err != nil {
+		return nil, err
+	}
+	n := int(data[0])
+	if n > smbusBlockMax {
+		return nil, fmt.Errorf("block length %d exceeds SMBus maximum of %d: %w", n, smbusBlockMax, ErrTruncated)
+	}
+	return append([]byte(nil), data[1:1+n]...), nil
+}
+
+// SMBusWriteBlock performs an SMBus block write to register reg. The
+// SMBus protocol transfers the length of data ahead of it, and limits
+// blocks to 32 bytes.
+func (c *Conn) SMBusWriteBlock(reg byte, data []byte) error {
+	if len(data) > smbusBlockMax {
+		return fmt.Errorf("block length %d exceeds SMBus maximum of %d: %w", len(data), smbusBlockMax, ErrInvalid)
+	}
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	var d smbusData
+	d[0] = byte(len(data))
+	copy(d[1:], data)
+	return c.smbus(smbusWrite, reg, smbusBlockData, &d)
+}
+
+// SMBusReadByte performs an SMBus receive byte transaction, reading a
+// single byte from the device without sending a register value.
+func (c *Conn) SMBusReadByte() (byte, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	var data smbusData
+	if err := c.smbus(smbusRead, 0, smbusByte, &data); err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// SMBusWriteByte performs an SMBus send byte transaction, writing the
+// single byte b to the device.
+func (c *Conn) SMBusWriteByte(b byte) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	return c.smbus(smbusWrite, b, smbusByte, nil)
+}
+
+// SMBusReadByteData performs an SMBus read byte data transaction,
+// reading the value of register reg as a single combined bus
+// transaction.
+func (c *Conn) SMBusReadByteData(reg byte) (byte, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	var data smbusData
+	if err := c.smbus(smbusRead, reg, smbusByteData, &data); err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// SMBusWriteByteData performs an SMBus write byte data transaction,
+// writing val to register reg.
+func (c *Conn) SMBusWriteByteData(reg, val byte) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	data := smbusData{val}
+	return c.smbus(smbusWrite, reg, smbusByteData, &data)
+}
+
+// withPEC runs fn with packet error checking enabled, restoring the
+// connection's PEC setting afterwards, even if fn fails. The caller
+// must hold c.mu.
+func (c *Conn) withPEC(fn func() error) error {
+	if c.pec {
+		return fn()
+	}
+	if err := c.ioctl(PEC, 1); err != nil {
+		return err
+	}
+	err := fn()
+	if rerr := c.ioctl(PEC, 0); rerr != nil {
+		err = errors.Join(err, fmt.Errorf("restoring PEC setting: %w", rerr))
+	}
+	return err
+}
+
+// ReadByteDataPEC performs an SMBus read byte data transaction, as for
+// SMBusReadByteData, with packet error checking enabled for just this
+// transaction. This suits buses where only some devices implement
+// PEC. See SetPEC.
+func (c *Conn) ReadByteDataPEC(reg byte) (byte, error) {
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	var data smbusData
+	err := c.withPEC(func() error {
+		return c.smbus(smbusRead, reg, smbusByteData, &data)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// WriteByteDataPEC performs an SMBus write byte data transaction, as
+// for SMBusWriteByteData, with packet error checking enabled for just
+// this transaction.
+func (c *Conn) WriteByteDataPEC(reg, val byte) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	data := smbusData{val}
+	return c.withPEC(func() error {
+		return c.smbus(smbusWrite, reg, smbusByteData, &data)
+	})
+}
+
+// Quick performs an SMBus quick command, which transfers no data:
+// the read/write bit of the address byte is the only information
+// conveyed, and is set according to write. It is the least intrusive
+// way to check that a device acknowledges its address.
+func (c *Conn) Quick(write bool) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	rw := uint8(smbusRead)
+	if write {
+		rw = smbusWrite
+	}
+	return c.smbus(rw, 0, smbusQuick, nil)
+}
+
+// ReadByte reads a single byte from the device with an SMBus receive
+// byte transaction. Unlike Read, this works with adapters that only
+// implement SMBus, while for adapters without SMBus receive byte
+// support, a one byte Read is used instead, with a short read
+// reported as ErrTruncated. It satisfies io.ByteReader.
+func (c *Conn) ReadByte() (byte, error) {
+	if c.Supports(FUNC_SMBUS_READ_BYTE) {
+		return c.SMBusReadByte()
+	}
+	var d [1]byte
+	if n, err := c.Read(d[:]); err != nil {
+		return 0, err
+	} else if n != 1 {
+		return 0, ErrTruncated
+	}
+	return d[0], nil
+}
+
+// WriteByte writes a single byte to the device with an SMBus send byte
+// transaction. Unlike Write, this works with adapters that only
+// implement SMBus, while for adapters without SMBus send byte
+// support, a one byte Write is used instead. It satisfies
+// io.ByteWriter.
+func (c *Conn) WriteByte(b byte) error {
+	if c.Supports(FUNC_SMBUS_WRITE_BYTE) {
+		return c.SMBusWriteByte(b)
+	}
+	if n, err := c.Write([]byte{b}); err != nil {
+		return err
+	} else if n != 1 {
+		return ErrTruncated
+	}
+	return nil
+}
+
+// ReadRegByte reads the single byte value of register reg using an
+// SMBus read byte data transaction. Where Reg performs a write of the
+// register value and a read as two separate bus transactions, with a
+// stop in between, this is a single transaction using a repeated
+// start, so another bus master cannot intervene. Reg is retained for
+// the rare devices that do not tolerate a repeated start.
+func (c *Conn) ReadRegByte(reg byte) (byte, error) {
+	return c.SMBusReadByteData(reg)
+}
+
+// WriteRegByte writes val to register reg using an SMBus write byte
+// data transaction.
+func (c *Conn) WriteRegByte(reg, val byte) error {
+	return c.SMBusWriteByteData(reg, val)
+}
+
+// ReadRegUint16 reads the 16-bit value of register reg using an SMBus
+// read word data transaction. See SMBusReadWordData for how the
+// connection's byte order applies.
+func (c *Conn) ReadRegUint16(reg byte) (uint16, error) {
+	return c.SMBusReadWordData(reg)
+}
+
+// WriteRegUint16 writes the 16-bit val to register reg using an SMBus
+// write word data transaction. See SMBusWriteWordData for how the
+// connection's byte order applies.
+func (c *Conn) WriteRegUint16(reg byte, val uint16) error {
+	return c.SMBusWriteWordData(reg, val)
+}
+
+// ReadBlock reads a variable length block from register reg using an
+// SMBus block read, in which the device sends the length of the block
+// ahead of its data. See SMBusReadBlock.
+func (c *Conn) ReadBlock(reg byte) ([]byte, error) {
+	return c.SMBusReadBlock(reg)
+}
+
+// WriteBlock writes data, of at most 32 bytes, to register reg using
+// an SMBus block write. See SMBusWriteBlock.
+func (c *Conn) WriteBlock(reg byte, data []byte) error {
+	return c.SMBusWriteBlock(reg, data)
+}
+
+// SMBusProcessCall performs an SMBus process call: val is written to
+// register reg and a 16-bit result is read back in the same
+// transaction. Both words honor the connection's byte order in the
+// same way as SMBusReadWordData and SMBusWriteWordData.
+func (c *Conn) SMBusProcessCall(reg byte, val uint16) (uint16, error) {
+	if _, err := c.byteOrder(); err != nil {
+		return 0, err
+	}
+	if err := c.lock(); err != nil {
+		return 0, err
+	}
+	defer c.mu.Unlock()
+	var data smbusData
+	hostEndian.PutUint16(data[:2], c.wordToWire(val))
+	if err := c.smbus(smbusWrite, reg, smbusProcCall, &data); err != nil {
+		return 0, err
+	}
+	return c.wordFromWire(hostEndian.Uint16(data[:2])), nil
+}
+
+// SMBusBlockProcessCall performs an SMBus block process call: the
+// block data is written to register reg and a block is read back in
+// the same transaction. Each block is limited to 32 bytes.
+func (c *Conn) SMBusBlockProcessCall(reg byte, data []byte) ([]byte, error) {
+	if len(data) > smbusBlockMax {
+		return nil, fmt.Errorf("block length %d exceeds SMBus maximum of %d: %w", len(data), smbusBlockMax, ErrInvalid)
+	}
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+	var d smbusData
+	d[0] = byte(len(data))
+	copy(d[1:], data)
+	if err := c.smbus(smbusWrite, reg, smbusBlockProcCall, &d); err != nil {
+		return nil, err
+	}
+	n := int(d[0])
+	if n > smbusBlockMax {
+		return nil, fmt.Errorf("block length %d exceeds SMBus maximum of %d: %w", n, smbusBlockMax, ErrTruncated)
+	}
+	return append([]byte(nil), d[1:1+n]...), nil
+}
@@ -0,0 +1,134 @@
+package i2c
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestSMBusIoctlLayout(t *testing.T) {
+	ptr := unsafe.Sizeof(uintptr(0))
+	vs := []struct {
+		name      string
+		got, want uintptr
+	}{
+		{"command", unsafe.Offsetof(smbusIoctlData{}.command), 1},
+		{"size", unsafe.Offsetof(smbusIoctlData{}.size), 4},
+		{"data", unsafe.Offsetof(smbusIoctlData{}.data), 8},
+		{"sizeof", unsafe.Sizeof(smbusIoctlData{}), 8 + ptr},
+		{"data size", unsafe.Sizeof(smbusData{}), 34},
+	}
+	for _, v := range vs {
+		if v.got != v.want {
+			t.Errorf("%s: got %d, want %d", v.name, v.got, v.want)
+		}
+	}
+}
+
+func TestQuick(t *testing.T) {
+	a := newFakeAdapter(0x48)
+	c := newFakeConn(t, a, 0x48, binary.BigEndian)
+	vs := []struct {
+		write bool
+		rw    uint8
+	}{
+		{true, smbusWrite},
+		{false, smbusRead},
+	}
+	for _, v := range vs {
+		a.smbus = smbusIoctlData{command: 0xff, size: 0xff}
+		if err := c.Quick(v.write); err != nil {
+			t.Fatalf("Quick(%v) failed: %v", v.write, err)
+		}
+		got := a.smbus
+		if got.readWrite != v.rw || got.command != 0 || got.size != smbusQuick || got.data != nil {
+			t.Errorf("Quick(%v) packed %+v, want readWrite=%d command=0 size=%d data=nil", v.write, got, v.rw, smbusQuick)
+		}
+	}
+	if err := c.SetAddr(0x49, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Quick(true); err != syscall.ENXIO {
+		t.Errorf("Quick of absent device got %v, want ENXIO", err)
+	}
+}
+
+func TestSMBusByteData(t *testing.T) {
+	a := newFakeAdapter(0x48)
+	c := newFakeConn(t, a, 0x48, binary.BigEndian)
+	if err := c.SMBusWriteByteData(0x21, 0x5a); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if a.smbus.readWrite != smbusWrite || a.smbus.command != 0x21 || a.smbus.size != smbusByteData {
+		t.Errorf("write packed %+v", a.smbus)
+	}
+	if v, err := c.SMBusReadByteData(0x21); err != nil || v != 0x5a {
+		t.Errorf("read got %#x, %v, want 0x5a", v, err)
+	}
+	if a.smbus.readWrite != smbusRead || a.smbus.command != 0x21 || a.smbus.size != smbusByteData {
+		t.Errorf("read packed %+v", a.smbus)
+	}
+}
+
+func TestSMBusBlock(t *testing.T) {
+	a := newFakeAdapter(0x0b)
+	c := newFakeConn(t, a, 0x0b, binary.LittleEndian)
+	if err := c.SMBusWriteBlock(0x20, []byte("ACME")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if d, err := c.SMBusReadBlock(0x20); err != nil || string(d) != "ACME" {
+		t.Errorf("read got %q, %v, want ACME", d, err)
+	}
+	if err := c.SMBusWriteBlock(0x22, nil); err != nil {
+		t.Fatalf("empty write failed: %v", err)
+	}
+	if d, err := c.SMBusReadBlock(0x22); err != nil || len(d) != 0 {
+		t.Errorf("empty read got %q, %v, want nothing", d, err)
+	}
+	full := []byte("0123456789abcdefghijklmnopqrstuv")
+	if err := c.SMBusWriteBlock(0x23, full); err != nil {
+		t.Fatalf("32 byte write failed: %v", err)
+	}
+	if got := a.devs[0x0b].blocks[0x23]; !bytes.Equal(got, full) {
+		t.Errorf("32 byte write stored %q, want %q", got, full)
+	}
+	if d, err := c.SMBusReadBlock(0x23); err != nil || !bytes.Equal(d, full) {
+		t.Errorf("32 byte read got %q, %v, want %q", d, err, full)
+	}
+	if err := c.SMBusWriteBlock(0x20, make([]byte, 33)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("33 byte block got %v, want ErrInvalid", err)
+	}
+	a.devs[0x0b].blocks[0x21] = make([]byte, 33)
+	if _, err := c.SMBusReadBlock(0x21); !errors.Is(err, ErrTruncated) {
+		t.Errorf("33 byte block read got %v, want ErrTruncated", err)
+	}
+}
+
+func TestPEC(t *testing.T) {
+	a := newFakeAdapter(0x0b)
+	c := newFakeConn(t, a, 0x0b, binary.LittleEndian)
+	a.devs[0x0b].badPEC = true
+	if _, err := c.SMBusReadByteData(1); err != nil {
+		t.Fatalf("read without PEC failed: %v", err)
+	}
+	if _, err := c.ReadByteDataPEC(1); !errors.Is(err, ErrPEC) || !errors.Is(err, syscall.EBADMSG) {
+		t.Errorf("read with bad PEC got %v, want ErrPEC and EBADMSG", err)
+	}
+	if c.f.(*fakeFile).pec {
+		t.Error("ReadByteDataPEC left PEC enabled")
+	}
+	a.devs[0x0b].badPEC = false
+	if err := c.SetPEC(true); err != nil {
+		t.Fatalf("SetPEC failed: %v", err)
+	}
+	a.ops()
+	if _, err := c.SMBusReadByteData(1); err != nil {
+		t.Fatalf("read with PEC failed: %v", err)
+	}
+	if got, want := a.ops(), []string{"smbus 0b: r 01 size=2 pec"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package i2c
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// checkFixed confirms that values of type t have a fixed size
+// encoding, and that every field of any struct is settable by
+// encoding/binary.
+func checkFixed(t reflect.Type) error {
+	switch t.Kind() {
+	case reflect.Array:
+		return checkFixed(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() && f.Name != "_" {
+				return fmt.Errorf("%v field %s is unexported: %w", t, f.Name, ErrInvalid)
+			}
+			if err := checkFixed(f.Type); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return nil
+	}
+	return fmt.Errorf("%v has no fixed size encoding: %w", t, ErrInvalid)
+}
+
+// structSize validates v, a pointer to a value with a fixed size
+// encoding, and returns the size of that encoding.
+func structSize(v any) (int, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return 0, fmt.Errorf("%T is not a non-nil pointer: %w", v, ErrInvalid)
+	}
+	if err := checkFixed(rv.Elem().Type()); err != nil {
+		return 0, err
+	}
+	n := binary.Size(v)
+	if n < 1 || n > maxMsg {
+		return 0, fmt.Errorf("%T encodes as %d bytes: %w", v, n, ErrInvalid)
+	}
+	return n, nil
+}
+
+// ReadStruct reads the fixed size value pointed to by v, typically a
+// struct describing a block of calibration data, with a single read
+// of binary.Size(v) bytes. The data is decoded with encoding/binary in
+// the connection's byte order. Values with slice, string or
+// unexported fields are rejected before the device is accessed. A
+// short read leaves v unchanged and returns a *TruncationError.
+func (c *Conn) ReadStruct(v any) error {
+	n, err := structSize(v)
+	if err != nil {
+		return err
+	}
+	order, err := c.byteOrder()
+	if err != nil {
+		return err
+	}
+	d := make([]byte, n)
+	if j, err := c.Read(d); err != nil {
+		return err
+	} else if j != n {
+		return &TruncationError{Op: "read", N: j, Want: n}
+	}
+	return binary.Read(bytes.NewReader(d), order, v)
+}
+
+// WriteStruct encodes the fixed size value pointed to by v with
+// encoding/binary, in the connection's byte order, and writes it with
+// a single write. The same values as for ReadStruct are rejected. A
+// short write returns a *TruncationError.
+func (c *Conn) WriteStruct(v any) error {
+	n, err := structSize(v)
+	if err != nil {
+		return err
+	}
+	order, err := c.byteOrder()
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := binary.Write(&b, order, v); err != nil {
+		return err
+	}
+	if j, err := c.Write(b.Bytes()); err != nil {
+		return err
+	} else if j != n {
+		return &TruncationError{Op: "write", N: j, Want: n}
+	}
+	return nil
+}
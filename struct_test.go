@@ -0,0 +1,90 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// calib is a block of calibration data as some sensors hold.
+type calib struct {
+	T1 uint16
+	T2 int16
+	_  [1]byte
+	P  [2]int8
+}
+
+func TestReadStruct(t *testing.T) {
+	a := newFakeAdapter(0x77)
+	d := a.devs[0x77]
+	c := newFakeConn(t, a, 0x77, binary.LittleEndian)
+	copy(d.regs[:], []byte{0x70, 0x6b, 0x43, 0xff, 0x00, 0x7f, 0x80})
+
+	var v calib
+	if err := c.ReadStruct(&v); err != nil {
+		t.Fatalf("ReadStruct failed: %v", err)
+	}
+	if want := (calib{T1: 0x6b70, T2: -189, P: [2]int8{127, -128}}); v != want {
+		t.Errorf("got %+v, want %+v", v, want)
+	}
+	if got, want := a.ops(), []string{"r 77: 7"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	v = calib{}
+	d.ptr, d.short = 0, 5
+	var te *TruncationError
+	if err := c.ReadStruct(&v); !errors.As(err, &te) || te.Op != "read" || te.N != 5 || te.Want != 7 {
+		t.Errorf("short read: got %v, want a 5 of 7 byte read TruncationError", err)
+	}
+	if v != (calib{}) {
+		t.Errorf("short read populated the struct: %+v", v)
+	}
+
+	rejects := []any{
+		calib{},
+		(*calib)(nil),
+		&struct{ S []byte }{},
+		&struct{ S string }{},
+		&struct{ u uint8 }{},
+		&struct{ I int }{},
+		&[maxMsg + 1]byte{},
+	}
+	a.ops()
+	for _, r := range rejects {
+		if err := c.ReadStruct(r); !errors.Is(err, ErrInvalid) {
+			t.Errorf("ReadStruct(%T): got %v, want %v", r, err, ErrInvalid)
+		}
+		if err := c.WriteStruct(r); !errors.Is(err, ErrInvalid) {
+			t.Errorf("WriteStruct(%T): got %v, want %v", r, err, ErrInvalid)
+		}
+	}
+	if ops := a.ops(); len(ops) != 0 {
+		t.Errorf("rejected values accessed the bus: %q", ops)
+	}
+}
+
+func TestWriteStruct(t *testing.T) {
+	a := newFakeAdapter(0x77)
+	d := a.devs[0x77]
+	c := newFakeConn(t, a, 0x77, binary.BigEndian)
+
+	v := calib{T1: 0x6b70, T2: -189, P: [2]int8{127, -128}}
+	if err := c.WriteStruct(&v); err != nil {
+		t.Fatalf("WriteStruct failed: %v", err)
+	}
+	if got, want := a.ops(), []string{"w 77: 6b 70 ff 43 00 7f 80"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	d.short = 3
+	var te *TruncationError
+	if err := c.WriteStruct(&v); !errors.As(err, &te) || te.Op != "write" || te.N != 3 || te.Want != 7 {
+		t.Errorf("short write: got %v, want a 3 of 7 byte write TruncationError", err)
+	}
+
+	c.endian = nil
+	if err := c.WriteStruct(&v); !errors.Is(err, ErrNoEndian) {
+		t.Errorf("no byte order: got %v, want %v", err, ErrNoEndian)
+	}
+}
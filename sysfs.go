@@ -0,0 +1,320 @@
+package i2c
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// sysfsRoot is where the kernel's sysfs filesystem is mounted.
+var sysfsRoot = "/sys"
+
+// openFile opens the files exported by kernel drivers. Tests replace
+// it to simulate access restrictions.
+var openFile = os.OpenFile
+
+// ErrNotBound etc are errors reported when accessing devices via the
+// files exported by kernel drivers.
+var (
+	ErrNotBound   = errors.New("no kernel driver bound")
+	ErrReadOnly   = errors.New("read-only device")
+	ErrPermission = errors.New("permission denied")
+	ErrNoCounters = errors.New("adapter exposes no error counters")
+)
+
+// sysfsDevice returns the sysfs directory of the device at addr on
+// the numbered bus.
+func sysfsDevice(bus, addr uint) string {
+	return filepath.Join(sysfsRoot, "bus", "i2c", "devices", fmt.Sprintf("%d-%04x", bus, addr))
+}
+
+// busNumber extracts the bus number from a bus device file name, as
+// returned by BusFile.
+func busNumber(bus string) (uint, error) {
+	base := filepath.Base(bus)
+	if !strings.HasPrefix(base, "i2c-") {
+		return 0, fmt.Errorf("%q is not a numbered bus: %w", bus, ErrInvalid)
+	}
+	n, err := strconv.ParseUint(base[4:], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a numbered bus: %w", bus, ErrInvalid)
+	}
+	return uint(n), nil
+}
+
+// sysfsAdapter returns the sysfs directory of the numbered bus
+// adapter.
+func sysfsAdapter(bus uint) string {
+	return filepath.Join(sysfsRoot, "class", "i2c-adapter", fmt.Sprintf("i2c-%d", bus))
+}
+
+// Buses returns the sorted numbers of the i2c buses present, as
+// listed by the i2c-dev driver in sysfs or, failing that, by the bus
+// device files in /dev. Pass each to BusFile to open the bus.
+func Buses() ([]uint, error) {
+	entries, err := os.ReadDir(filepath.Join(sysfsRoot, "class", "i2c-dev"))
+	if errors.Is(err, fs.ErrNotExist) {
+		entries, err = os.ReadDir("/dev")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var buses []uint
+	for _, e := range entries {
+		if n, err := busNumber(e.Name()); err == nil {
+			buses = append(buses, n)
+		}
+	}
+	sort.Slice(buses, func(i, j int) bool { return buses[i] < buses[j] })
+	return buses, nil
+}
+
+// BoundDriver returns the name of the kernel driver bound to the
+// device at addr on the numbered bus. An empty string indicates no
+// driver is bound, so userspace access via NewConn should work.
+func BoundDriver(bus, addr uint) (string, error) {
+	link, err := os.Readlink(filepath.Join(sysfsDevice(bus, addr), "driver"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(link), nil
+}
+
+// EEPROM holds an open EEPROM content file exported by a kernel
+// driver (typically at24). When such a driver is bound to an address,
+// userspace i2c access to it is refused, but the content remains
+// accessible this way. The kernel driver takes care of the device's
+// page and addressing details.
+type EEPROM struct {
+	mu       sync.Mutex
+	f        *os.File
+	size     int64
+	readOnly bool
+}
+
+// sysfsErr maps file access errors to the package's typed errors.
+func sysfsErr(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%w: %v", ErrNotBound, err)
+	case errors.Is(err, syscall.EROFS):
+		return fmt.Errorf("%w: %v", ErrReadOnly, err)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("%w: %v", ErrPermission, err)
+	}
+	return err
+}
+
+// SysfsEEPROM opens the kernel exported content of the EEPROM at addr
+// on the numbered bus. If the file is not writable by the caller, the
+// EEPROM is opened read-only and WriteAt returns ErrReadOnly.
+func SysfsEEPROM(bus, addr uint) (*EEPROM, error) {
+	name := filepath.Join(sysfsDevice(bus, addr), "eeprom")
+	readOnly := false
+	f, err := openFile(name, os.O_RDWR, 0)
+	if errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EROFS) {
+		readOnly = true
+		f, err = openFile(name, os.O_RDONLY, 0)
+	}
+	if err != nil {
+		return nil, sysfsErr(err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &EEPROM{f: f, size: fi.Size(), readOnly: readOnly}, nil
+}
+
+// Size returns the size in bytes of the EEPROM.
+func (e *EEPROM) Size() int64 {
+	return e.size
+}
+
+// ReadOnly indicates the EEPROM was opened without write access.
+func (e *EEPROM) ReadOnly() bool {
+	return e.readOnly
+}
+
+// ReadAt reads len(p) bytes of EEPROM content starting at offset off.
+// It satisfies io.ReaderAt.
+func (e *EEPROM) ReadAt(p []byte, off int64) (int, error) {
+	if e == nil {
+		return 0, ErrInvalid
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.f == nil {
+		return 0, ErrClosed
+	}
+	if off < 0 {
+		return 0, ErrInvalid
+	}
+	if off >= e.size {
+		return 0, io.EOF
+	}
+	n, err := e.f.ReadAt(p, off)
+	if err != nil && err != io.EOF {
+		err = sysfsErr(err)
+	}
+	return n, err
+}
+
+// WriteAt writes p to the EEPROM starting at offset off. It satisfies
+// io.WriterAt. Writes extending beyond the end of the EEPROM are
+// truncated and return ErrTruncated.
+func (e *EEPROM) WriteAt(p []byte, off int64) (int, error) {
+	if e == nil {
+		return 0, ErrInvalid
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.f == nil {
+		return 0, ErrClosed
+	}
+	if e.readOnly {
+		return 0, ErrReadOnly
+	}
+	if off < 0 || off > e.size {
+		return 0, ErrInvalid
+	}
+	short := false
+	if rem := e.size - off; int64(len(p)) > rem {
+		p = p[:rem]
+		short = true
+	}
+	n, err := e.f.WriteAt(p, off)
+	if err != nil {
+		return n, sysfsErr(err)
+	}
+	if short {
+		return n, ErrTruncated
+	}
+	return n, nil
+}
+
+// Close closes the EEPROM file.
+func (e *EEPROM) Close() error {
+	if e == nil {
+		return ErrInvalid
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.f == nil {
+		return ErrClosed
+	}
+	err := e.f.Close()
+	e.f = nil
+	return err
+}
+
+// busBusy reports the content of a bus_busy attribute of the numbered
+// bus adapter. The bool return value is false if the adapter has no
+// such attribute.
+func busBusy(bus uint) (busy, ok bool) {
+	d, err := os.ReadFile(filepath.Join(sysfsAdapter(bus), "bus_busy"))
+	if err != nil {
+		return false, false
+	}
+	return strings.TrimSpace(string(d)) != "0", true
+}
+
+// WaitIdle waits up to timeout for the bus to become idle, that is, no
+// other bus master is driving it. This can avoid losing arbitration
+// on a multi-master bus during a critical transaction. The kernel has
+// no standard busy indicator, but if the adapter's sysfs directory
+// holds a bus_busy attribute, as some out of tree drivers provide, it
+// is consulted. Otherwise, the wait is only best-effort: WaitIdle
+// repeatedly probes the connection's device, with the method
+// ProbeAuto selects for its address, so EEPROMs are only read, until
+// the adapter stops reporting lost arbitration or a busy bus. A probe
+// the device does not acknowledge still shows the bus is idle. Other
+// probe errors are returned, and ErrTimeout if the bus remains busy.
+func (c *Conn) WaitIdle(timeout time.Duration) error {
+	if err := c.lock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	n, err := busNumber(c.bus)
+	attr := err == nil
+	m := (&ScanPolicy{TenBit: c.tenBit}).method(c.addr)
+	deadline := time.Now().Add(timeout)
+	for {
+		if attr {
+			var busy bool
+			if busy, attr = busBusy(n); attr && !busy {
+				return nil
+			}
+		}
+		if !attr {
+			err := c.probe(m)
+			switch {
+			case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EBUSY):
+			case err == nil, absent(err):
+				return nil
+			default:
+				return err
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("bus %q still busy after %v: %w", c.bus, timeout, ErrTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// AdapterErrors returns the transfer error counters, for example of
+// lost arbitration, unacknowledged transfers and timeouts, that some
+// adapter drivers export in a statistics directory of the adapter's
+// sysfs directory. The counters are keyed by their file names, which
+// are driver specific. An error wrapping ErrNoCounters is returned if
+// the adapter of the connection's bus exports none.
+func (c *Conn) AdapterErrors() (map[string]uint64, error) {
+	if c == nil {
+		return nil, ErrInvalid
+	}
+	n, err := busNumber(c.bus)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(sysfsAdapter(n), "statistics")
+	ents, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("bus %q: %w", c.bus, ErrNoCounters)
+	}
+	if err != nil {
+		return nil, sysfsErr(err)
+	}
+	counts := make(map[string]uint64)
+	for _, ent := range ents {
+		if !ent.Type().IsRegular() {
+			continue
+		}
+		d, err := os.ReadFile(filepath.Join(dir, ent.Name()))
+		if err != nil {
+			return nil, sysfsErr(err)
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(string(d)), 10, 64)
+		if err != nil {
+			continue
+		}
+		counts[ent.Name()] = v
+	}
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("bus %q: %w", c.bus, ErrNoCounters)
+	}
+	return counts, nil
+}
@@ -0,0 +1,286 @@
+package i2c
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// useSysfs makes the package read sysfs from a temporary directory,
+// which it returns.
+func useSysfs(t *testing.T) string {
+	t.Helper()
+	old := sysfsRoot
+	sysfsRoot = t.TempDir()
+	t.Cleanup(func() { sysfsRoot = old })
+	return sysfsRoot
+}
+
+func TestWaitIdleProbe(t *testing.T) {
+	useSysfs(t)
+	a := newFakeAdapter(0x20, 0x50)
+	bus := useFake(t, a, 3)
+	vs := []struct {
+		name string
+		addr uint
+		errs []error
+		op   string
+		err  error
+	}{
+		{"idle", 0x20, nil, "smbus 20: w 00 size=0", nil},
+		{"eeprom", 0x50, nil, "smbus 50: r 00 size=1", nil},
+		{"absent", 0x21, nil, "smbus 21: w 00 size=0", nil},
+		{"busy", 0x20, []error{syscall.EAGAIN, syscall.EBUSY}, "smbus 20: w 00 size=0", nil},
+		{"unsupported", 0x20, []error{syscall.EOPNOTSUPP}, "smbus 20: w 00 size=0", syscall.EOPNOTSUPP},
+	}
+	for _, v := range vs {
+		c, err := NewConn(bus, v.addr, false, nil)
+		if err != nil {
+			t.Fatalf("%s: NewConn failed: %v", v.name, err)
+		}
+		a.ops()
+		a.quickErrs = v.errs
+		err = c.WaitIdle(time.Second)
+		c.Close()
+		if v.err == nil && err != nil {
+			t.Errorf("%s: WaitIdle failed: %v", v.name, err)
+		} else if v.err != nil && !errors.Is(err, v.err) {
+			t.Errorf("%s: got %v, want %v", v.name, err, v.err)
+		}
+		ops := a.ops()
+		want := len(v.errs) + 1
+		if v.err != nil {
+			want = 1
+		}
+		if len(ops) != want {
+			t.Errorf("%s: got %d probes, want %d: %q", v.name, len(ops), want, ops)
+		}
+		for _, op := range ops {
+			if op != v.op {
+				t.Errorf("%s: got probe %q, want %q", v.name, op, v.op)
+			}
+		}
+	}
+
+	c, err := NewConn(bus, 0x20, false, nil)
+	if err != nil {
+		t.Fatalf("NewConn failed: %v", err)
+	}
+	defer c.Close()
+	for i := 0; i < 1000; i++ {
+		a.quickErrs = append(a.quickErrs, syscall.EAGAIN)
+	}
+	if err := c.WaitIdle(5 * time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("busy bus got %v, want ErrTimeout", err)
+	}
+}
+
+func TestWaitIdleAttr(t *testing.T) {
+	root := useSysfs(t)
+	dir := filepath.Join(root, "class", "i2c-adapter", "i2c-3")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	attr := filepath.Join(dir, "bus_busy")
+	a := newFakeAdapter(0x20)
+	c, err := NewConn(useFake(t, a, 3), 0x20, false, nil)
+	if err != nil {
+		t.Fatalf("NewConn failed: %v", err)
+	}
+	defer c.Close()
+	a.ops()
+
+	if err := os.WriteFile(attr, []byte("1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitIdle(5 * time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("busy bus got %v, want ErrTimeout", err)
+	}
+	if err := os.WriteFile(attr, []byte("0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitIdle(5 * time.Millisecond); err != nil {
+		t.Errorf("idle bus got %v", err)
+	}
+	if ops := a.ops(); len(ops) != 0 {
+		t.Errorf("device probed despite bus_busy: %s", strings.Join(ops, ", "))
+	}
+}
+
+// sysfsEEPROMDevice creates the sysfs directory of a device at addr
+// on bus 1, bound to the at24 driver and exporting content as its
+// eeprom file, and returns the directory.
+func sysfsEEPROMDevice(t *testing.T, root string, addr uint, content []byte) string {
+	t.Helper()
+	dir := filepath.Join(root, "bus", "i2c", "devices", fmt.Sprintf("1-%04x", addr))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../../../bus/i2c/drivers/at24", filepath.Join(dir, "driver")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "eeprom"), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// restrictOpen makes openFile fail with rw for files opened for
+// writing and with ro for those opened read-only. A nil error opens
+// the file.
+func restrictOpen(t *testing.T, rw, ro error) {
+	old := openFile
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		err := ro
+		if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+			err = rw
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return old(name, flag, perm)
+	}
+	t.Cleanup(func() { openFile = old })
+}
+
+func TestBoundDriver(t *testing.T) {
+	root := useSysfs(t)
+	sysfsEEPROMDevice(t, root, 0x50, nil)
+	if err := os.MkdirAll(filepath.Join(root, "bus", "i2c", "devices", "1-0051"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	vs := []struct {
+		addr uint
+		want string
+	}{
+		{0x50, "at24"},
+		{0x51, ""},
+		{0x52, ""},
+	}
+	for _, v := range vs {
+		if got, err := BoundDriver(1, v.addr); err != nil || got != v.want {
+			t.Errorf("%#x: got %q, %v, want %q", v.addr, got, err, v.want)
+		}
+	}
+}
+
+func TestSysfsEEPROM(t *testing.T) {
+	root := useSysfs(t)
+	content := make([]byte, 256)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	dir := sysfsEEPROMDevice(t, root, 0x50, content)
+
+	e, err := SysfsEEPROM(1, 0x50)
+	if err != nil {
+		t.Fatalf("SysfsEEPROM failed: %v", err)
+	}
+	if e.Size() != 256 || e.ReadOnly() {
+		t.Errorf("got size %d read-only %v, want 256 writable", e.Size(), e.ReadOnly())
+	}
+	buf := make([]byte, 4)
+	if n, err := e.ReadAt(buf, 0x10); err != nil || n != 4 || !bytes.Equal(buf, content[0x10:0x14]) {
+		t.Errorf("ReadAt got %d, % x, %v", n, buf, err)
+	}
+	if _, err := e.ReadAt(buf, 256); err != io.EOF {
+		t.Errorf("ReadAt past the end got %v, want io.EOF", err)
+	}
+	if n, err := e.WriteAt([]byte{0xaa, 0xbb, 0xcc}, 254); err != ErrTruncated || n != 2 {
+		t.Errorf("WriteAt over the end got %d, %v, want 2, ErrTruncated", n, err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if d, _ := os.ReadFile(filepath.Join(dir, "eeprom")); len(d) != 256 || d[254] != 0xaa || d[255] != 0xbb {
+		t.Errorf("file holds % x at its end, want aa bb", d[254:])
+	}
+	if _, err := e.ReadAt(buf, 0); err != ErrClosed {
+		t.Errorf("ReadAt after Close got %v, want ErrClosed", err)
+	}
+
+	if _, err := SysfsEEPROM(1, 0x51); !errors.Is(err, ErrNotBound) {
+		t.Errorf("unbound device got %v, want ErrNotBound", err)
+	}
+}
+
+func TestSysfsEEPROMRestricted(t *testing.T) {
+	root := useSysfs(t)
+	sysfsEEPROMDevice(t, root, 0x50, make([]byte, 128))
+	for _, rw := range []error{syscall.EROFS, syscall.EACCES} {
+		restrictOpen(t, rw, nil)
+		e, err := SysfsEEPROM(1, 0x50)
+		if err != nil {
+			t.Errorf("%v: SysfsEEPROM failed: %v", rw, err)
+			continue
+		}
+		if !e.ReadOnly() {
+			t.Errorf("%v: EEPROM is not read-only", rw)
+		}
+		if _, err := e.ReadAt(make([]byte, 8), 0); err != nil {
+			t.Errorf("%v: ReadAt failed: %v", rw, err)
+		}
+		if _, err := e.WriteAt([]byte{1}, 0); err != ErrReadOnly {
+			t.Errorf("%v: WriteAt got %v, want ErrReadOnly", rw, err)
+		}
+		e.Close()
+	}
+
+	restrictOpen(t, syscall.EACCES, syscall.EACCES)
+	if _, err := SysfsEEPROM(1, 0x50); !errors.Is(err, ErrPermission) {
+		t.Errorf("unreadable EEPROM got %v, want ErrPermission", err)
+	}
+}
+
+func TestAdapterErrors(t *testing.T) {
+	root := useSysfs(t)
+	a := newFakeAdapter(0x20)
+	c, err := NewConn(useFake(t, a, 4), 0x20, false, nil)
+	if err != nil {
+		t.Fatalf("NewConn failed: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.AdapterErrors(); !errors.Is(err, ErrNoCounters) {
+		t.Errorf("no statistics directory got %v, want ErrNoCounters", err)
+	}
+
+	dir := filepath.Join(root, "class", "i2c-adapter", "i2c-4", "statistics")
+	if err := os.MkdirAll(filepath.Join(dir, "per_addr"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AdapterErrors(); !errors.Is(err, ErrNoCounters) {
+		t.Errorf("empty statistics directory got %v, want ErrNoCounters", err)
+	}
+	files := map[string]string{
+		"arbitration_lost": "3\n",
+		"nacks":            "1042\n",
+		"timeouts":         "0\n",
+		"description":      "not a counter\n",
+	}
+	for name, v := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(v), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := c.AdapterErrors()
+	if err != nil {
+		t.Fatalf("AdapterErrors failed: %v", err)
+	}
+	want := map[string]uint64{"arbitration_lost": 3, "nacks": 1042, "timeouts": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := newFakeConn(t, a, 0x20, nil).AdapterErrors(); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unnumbered bus got %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,94 @@
+package i2c
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// snapshot reads each of the listed registers.
+func (c *Conn) snapshot(regs []byte) (map[byte]byte, error) {
+	vals := make(map[byte]byte, len(regs))
+	for _, reg := range regs {
+		var v [1]byte
+		if _, err := c.ReadRegBuf(reg, v[:]); err != nil {
+			return nil, fmt.Errorf("register %02xh: %w", reg, err)
+		}
+		vals[reg] = v[0]
+	}
+	return vals, nil
+}
+
+// Watch polls the listed registers every interval and sends on the
+// returned channel the registers whose values changed since the
+// previous poll. The first value sent is a snapshot of all of the
+// registers. Polls that find no change send nothing. The channel is
+// closed when ctx is done, or if a later poll fails. This is useful
+// for observing the live state of a device, for example the time
+// registers of a clock.
+func (c *Conn) Watch(ctx context.Context, regs []byte, interval time.Duration) (<-chan map[byte]byte, error) {
+	if len(regs) == 0 || interval <= 0 {
+		return nil, ErrInvalid
+	}
+	last, err := c.snapshot(regs)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan map[byte]byte, 1)
+	initial := make(map[byte]byte, len(last))
+	for reg, v := range last {
+		initial[reg] = v
+	}
+	ch <- initial
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			vals, err := c.snapshot(regs)
+			if err != nil {
+				return
+			}
+			changed := make(map[byte]byte)
+			for reg, v := range vals {
+				if last[reg] != v {
+					changed[reg] = v
+				}
+			}
+			last = vals
+			if len(changed) == 0 {
+				continue
+			}
+			select {
+			case ch <- changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// GaugeReader returns a function that reads n bytes starting at
+// register reg and converts them to a value with transform. The
+// function suits gauge metrics collected with a callback, for example
+// a Prometheus GaugeFunc, without this package depending on a metrics
+// library. It returns NaN if the read fails.
+func (c *Conn) GaugeReader(reg byte, n int, transform func([]byte) float64) func() float64 {
+	return func() float64 {
+		if n < 1 || n > maxMsg {
+			return math.NaN()
+		}
+		d := make([]byte, n)
+		if _, err := c.ReadRegBuf(reg, d); err != nil {
+			return math.NaN()
+		}
+		return transform(d)
+	}
+}
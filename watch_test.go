@@ -0,0 +1,104 @@
+package i2c
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	a := newFakeAdapter(0x68)
+	c := newFakeConn(t, a, 0x68, binary.BigEndian)
+	d := a.devs[0x68]
+	// The values of registers 10h through 12h seen by each poll.
+	polls := [][3]byte{{1, 2, 3}, {1, 2, 3}, {1, 5, 3}, {7, 5, 9}}
+	n := 0
+	d.onRead = func(buf []byte) {
+		if d.ptr == 0x10 {
+			n++
+		}
+		p := polls[len(polls)-1]
+		if n <= len(polls) {
+			p = polls[n-1]
+		}
+		buf[0] = p[d.ptr-0x10]
+	}
+
+	if _, err := c.Watch(context.Background(), nil, time.Millisecond); !errors.Is(err, ErrInvalid) {
+		t.Errorf("no registers: got %v, want %v", err, ErrInvalid)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := c.Watch(ctx, []byte{0x10, 0x11, 0x12}, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	want := []map[byte]byte{
+		{0x10: 1, 0x11: 2, 0x12: 3},
+		{0x11: 5},
+		{0x10: 7, 0x12: 9},
+	}
+	for i, w := range want {
+		select {
+		case got, ok := <-ch:
+			if !ok {
+				t.Fatalf("change %d: channel closed", i)
+			}
+			if !reflect.DeepEqual(got, w) {
+				t.Errorf("change %d: got %v, want %v", i, got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("change %d: timed out waiting for %v", i, w)
+		}
+	}
+
+	cancel()
+	select {
+	case got, ok := <-ch:
+		if ok {
+			t.Errorf("unexpected change %v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+
+	d.onRead = nil
+	d.nak = true
+	if _, err := c.Watch(context.Background(), []byte{0x10}, time.Millisecond); err == nil {
+		t.Error("Watch of an absent device succeeded")
+	}
+}
+
+func TestGaugeReader(t *testing.T) {
+	a := newFakeAdapter(0x48)
+	d := a.devs[0x48]
+	c := newFakeConn(t, a, 0x48, binary.BigEndian)
+	d.regs[0x00], d.regs[0x01] = 0x19, 0x80
+	celsius := func(b []byte) float64 {
+		return float64(int16(binary.BigEndian.Uint16(b))) / 256
+	}
+
+	g := c.GaugeReader(0x00, 2, celsius)
+	if v := g(); v != 25.5 {
+		t.Errorf("got %v, want 25.5", v)
+	}
+	if got, want := a.ops(), []string{"w 48: 00", "r 48: 2"}; !equalStrings(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	d.regs[0x00] = 0xe7
+	if v := g(); v != -24.5 {
+		t.Errorf("second read: got %v, want -24.5", v)
+	}
+
+	d.nak = true
+	if v := g(); !math.IsNaN(v) {
+		t.Errorf("absent device: got %v, want NaN", v)
+	}
+	if v := c.GaugeReader(0x00, 0, celsius)(); !math.IsNaN(v) {
+		t.Errorf("zero length: got %v, want NaN", v)
+	}
+}